@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/debyltech/go-snipcart-webhook/config"
+	"github.com/debyltech/go-snipcart/snipcart"
+)
+
+const (
+	AddressRuleNameFormat         string = "name_format"
+	AddressRuleRequiredFields     string = "required_fields"
+	AddressRulePOBox              string = "po_box"
+	AddressRuleInternationalPhone string = "international_phone"
+	AddressRulePostalCode         string = "postal_code"
+	AddressRuleLineLength         string = "line_length"
+	AddressRuleNonLatin           string = "non_latin"
+
+	// DefaultAddressLineLength is the line length limit when a line_length
+	// rule does not set one, which most carriers accept
+	DefaultAddressLineLength int = 35
+)
+
+// AddressRule checks the shipping address with the settings of its configured
+// rule, returning an error for each problem found
+type AddressRule func(address snipcart.Address, rule *config.AddressRule, locale *Locale) []snipcart.ShippingError
+
+var (
+	addressRules map[string]AddressRule = map[string]AddressRule{
+		AddressRuleNameFormat:         nameFormatRule,
+		AddressRuleRequiredFields:     requiredFieldsRule,
+		AddressRulePOBox:              poBoxRule,
+		AddressRuleInternationalPhone: internationalPhoneRule,
+		AddressRulePostalCode:         postalCodeRule,
+		AddressRuleLineLength:         lineLengthRule,
+		AddressRuleNonLatin:           nonLatinRule,
+	}
+
+	poBoxPattern *regexp.Regexp = regexp.MustCompile(`(?i)\b(p\.?\s*o\.?\s*box|post\s+office\s+box|postfach|bo[iî]te\s+postale)\b`)
+)
+
+// RegisterAddressRule adds a rule that can be enabled by name in the address
+// rules config, replacing any rule with the same name
+func RegisterAddressRule(name string, rule AddressRule) {
+	addressRules[name] = rule
+}
+
+// CheckAddressRules ensures every configured address rule is registered
+func CheckAddressRules(rules []config.AddressRule) error {
+	for _, rule := range rules {
+		if _, ok := addressRules[rule.Name]; !ok {
+			return fmt.Errorf("unknown address rule '%s'", rule.Name)
+		}
+	}
+
+	return nil
+}
+
+func nameFormatRule(address snipcart.Address, rule *config.AddressRule, locale *Locale) []snipcart.ShippingError {
+	// Ensure the name of the shipping address has at least two words
+	name := strings.Fields(address.Name)
+
+	if len(name) <= 1 {
+		return []snipcart.ShippingError{
+			{
+				Key:     "invalid_address_name",
+				Message: locale.Text(MsgInvalidAddressName),
+			},
+		}
+	}
+
+	// Ensure the first name of the shipping address has more than two characters
+	if len(name[0]) <= 2 {
+		return []snipcart.ShippingError{
+			{
+				Key:     "invalid_address_firstname_length",
+				Message: locale.Text(MsgInvalidAddressFirstName),
+			},
+		}
+	}
+
+	return nil
+}
+
+func poBoxRule(address snipcart.Address, rule *config.AddressRule, locale *Locale) []snipcart.ShippingError {
+	if !poBoxPattern.MatchString(address.Address1) && !poBoxPattern.MatchString(address.Address2) {
+		return nil
+	}
+
+	return []snipcart.ShippingError{
+		{
+			Key:     "invalid_address_po_box",
+			Message: locale.Text(MsgInvalidAddressPOBox),
+		},
+	}
+}
+
+func internationalPhoneRule(address snipcart.Address, rule *config.AddressRule, locale *Locale) []snipcart.ShippingError {
+	if !IsInternational(address.Country) || strings.TrimSpace(address.Phone) != "" {
+		return nil
+	}
+
+	return []snipcart.ShippingError{
+		{
+			Key:     "missing_address_phone",
+			Message: locale.Text(MsgMissingAddressPhone),
+		},
+	}
+}
+
+func requiredFieldsRule(address snipcart.Address, rule *config.AddressRule, locale *Locale) []snipcart.ShippingError {
+	var shippingErrors []snipcart.ShippingError
+
+	if strings.TrimSpace(address.Address1) == "" {
+		shippingErrors = append(shippingErrors, snipcart.ShippingError{
+			Key:     "missing_address_address1",
+			Message: locale.Text(MsgMissingAddressStreet),
+		})
+	}
+
+	if strings.TrimSpace(address.City) == "" {
+		shippingErrors = append(shippingErrors, snipcart.ShippingError{
+			Key:     "missing_address_city",
+			Message: locale.Text(MsgMissingAddressCity),
+		})
+	}
+
+	if IsStateRequired(address.Country) && strings.TrimSpace(address.Province) == "" {
+		shippingErrors = append(shippingErrors, snipcart.ShippingError{
+			Key:     "missing_address_province",
+			Message: locale.Text(MsgMissingAddressProvince),
+		})
+	}
+
+	if format, ok := PostalCodeFormatFor(address.Country); ok && !format.Optional && NormalizePostalCode(address.PostalCode) == "" {
+		shippingErrors = append(shippingErrors, snipcart.ShippingError{
+			Key:     "missing_address_postal_code",
+			Message: locale.Text(MsgMissingAddressPostalCode),
+		})
+	}
+
+	return shippingErrors
+}
+
+// postalCodeRule checks entered postal codes against the rule's pattern, or
+// the country's built in format when the rule has none. Missing postal codes
+// are left to the required_fields rule
+func postalCodeRule(address snipcart.Address, rule *config.AddressRule, locale *Locale) []snipcart.ShippingError {
+	postalCode := NormalizePostalCode(address.PostalCode)
+	if postalCode == "" {
+		return nil
+	}
+
+	if rule.PatternRegexp != nil {
+		if rule.PatternRegexp.MatchString(postalCode) {
+			return nil
+		}
+
+		return []snipcart.ShippingError{
+			{
+				Key:     "invalid_address_postal_code",
+				Message: locale.Text(MsgInvalidAddressPostalCodeFormat),
+			},
+		}
+	}
+
+	format, ok := PostalCodeFormatFor(address.Country)
+	if !ok || format.Pattern.MatchString(postalCode) {
+		return nil
+	}
+
+	return []snipcart.ShippingError{
+		{
+			Key:     "invalid_address_postal_code",
+			Message: locale.Text(MsgInvalidAddressPostalCodeExample, format.Example),
+		},
+	}
+}
+
+func lineLengthRule(address snipcart.Address, rule *config.AddressRule, locale *Locale) []snipcart.ShippingError {
+	maxLength := rule.MaxLength
+	if maxLength <= 0 {
+		maxLength = DefaultAddressLineLength
+	}
+
+	for _, line := range []string{address.Name, address.Company, address.Address1, address.Address2, address.City} {
+		if len([]rune(line)) > maxLength {
+			return []snipcart.ShippingError{
+				{
+					Key:     "invalid_address_line_length",
+					Message: locale.Text(MsgInvalidAddressLineLength, maxLength),
+				},
+			}
+		}
+	}
+
+	return nil
+}
+
+func nonLatinRule(address snipcart.Address, rule *config.AddressRule, locale *Locale) []snipcart.ShippingError {
+	for _, field := range []string{address.Name, address.Company, address.Address1, address.Address2, address.City, address.Province} {
+		for _, r := range field {
+			if unicode.IsLetter(r) && !unicode.Is(unicode.Latin, r) {
+				return []snipcart.ShippingError{
+					{
+						Key:     "invalid_address_characters",
+						Message: locale.Text(MsgInvalidAddressCharacters),
+					},
+				}
+			}
+		}
+	}
+
+	return nil
+}
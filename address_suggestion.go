@@ -0,0 +1,132 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/EasyPost/easypost-go/v4"
+	"github.com/debyltech/go-snipcart/snipcart"
+)
+
+// AddressChange is a field of the shipping address that verification
+// corrected, using EasyPost's field names
+type AddressChange struct {
+	Field    string `json:"field"`
+	Original string `json:"original"`
+	Verified string `json:"verified"`
+}
+
+// DiffAddress returns the fields of the address that verification corrected,
+// ignoring differences in case and surrounding whitespace
+func DiffAddress(original *easypost.Address, verified *easypost.Address) []AddressChange {
+	fields := []struct {
+		name     string
+		original string
+		verified string
+	}{
+		{"street1", original.Street1, verified.Street1},
+		{"street2", original.Street2, verified.Street2},
+		{"city", original.City, verified.City},
+		{"state", original.State, verified.State},
+		{"zip", original.Zip, verified.Zip},
+	}
+
+	var changes []AddressChange
+	for _, field := range fields {
+		if strings.EqualFold(strings.TrimSpace(field.original), strings.TrimSpace(field.verified)) {
+			continue
+		}
+
+		changes = append(changes, AddressChange{
+			Field:    field.name,
+			Original: field.original,
+			Verified: field.verified,
+		})
+	}
+
+	return changes
+}
+
+// IsZIP4Only reports whether the only correction is the ZIP+4 extension being
+// added to the postal code, which is not worth asking the customer about
+func IsZIP4Only(changes []AddressChange) bool {
+	return len(changes) == 1 && changes[0].Field == "zip" &&
+		strings.HasPrefix(changes[0].Verified, strings.TrimSpace(changes[0].Original))
+}
+
+// ApplyVerifiedAddress replaces the address fields with the verified ones,
+// keeping the customer's name, company, phone, and email as they were entered
+func ApplyVerifiedAddress(address *easypost.Address, verified *easypost.Address) {
+	address.Street1 = verified.Street1
+	address.Street2 = verified.Street2
+	address.City = verified.City
+	address.State = verified.State
+	address.Zip = verified.Zip
+}
+
+// FormatAddress formats the address on a single line for customer facing
+// messages
+func FormatAddress(address *easypost.Address) string {
+	var parts []string
+	for _, part := range []string{address.Street1, address.Street2, address.City, strings.TrimSpace(address.State + " " + address.Zip)} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// AddressSuggestionKey creates the cache key remembering that a suggestion was
+// shown for the address the customer entered on the order
+func AddressSuggestionKey(token string, address snipcart.Address) string {
+	addressBytes, _ := json.Marshal(address)
+	hash := sha256.Sum256(addressBytes)
+
+	return fmt.Sprintf("suggestion-%s-%s", token, hex.EncodeToString(hash[:]))
+}
+
+// SuggestAddress returns a shipping error showing the corrected address, unless
+// the same correction was already shown for the address the customer entered,
+// in which case submitting it again confirms it. A different correction than
+// the one shown is suggested again. Suggestions are remembered in the shipment
+// cache, so none are shown when caching is disabled to avoid asking forever
+func SuggestAddress(order *snipcart.Order, verified *easypost.Address, locale *Locale) *snipcart.ShippingErrors {
+	if shipmentCache == nil {
+		return nil
+	}
+
+	key := AddressSuggestionKey(order.Token, order.ShippingAddress)
+
+	entry, err := shipmentCache.Get(key)
+	if err != nil {
+		logJsonWithStatus(JsonLogStatusWarning, "shipment.cache", fmt.Sprintf("error with getting %s: %s", key, err.Error()))
+		return nil
+	}
+	if entry != nil && entry.SuggestedAddress != nil && len(DiffAddress(entry.SuggestedAddress, verified)) == 0 {
+		DebugPrintf("address suggestion confirmed for %s", order.Token)
+		return nil
+	}
+
+	err = shipmentCache.Put(key, &ShipmentCacheEntry{
+		SuggestedAddress: verified,
+		CreatedAt:        time.Now(),
+	})
+	if err != nil {
+		logJsonWithStatus(JsonLogStatusWarning, "shipment.cache", fmt.Sprintf("error with putting %s: %s", key, err.Error()))
+		return nil
+	}
+
+	return &snipcart.ShippingErrors{
+		Errors: []snipcart.ShippingError{
+			{
+				Key:     "address_suggestion",
+				Message: locale.Text(MsgAddressSuggestion, FormatAddress(verified)),
+			},
+		},
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/EasyPost/easypost-go/v4"
+)
+
+func TestDiffAddress(t *testing.T) {
+	original := easypost.Address{Name: "Jane Doe", Street1: "1 main st", City: "Springfield", State: "IL", Zip: "62701", Country: "US"}
+
+	tests := []struct {
+		name     string
+		verified func(a *easypost.Address)
+		want     []string
+		zip4Only bool
+	}{
+		{"unchanged", func(a *easypost.Address) {}, nil, false},
+		{"case and whitespace", func(a *easypost.Address) { a.Street1 = " 1 MAIN ST "; a.City = "SPRINGFIELD" }, nil, false},
+		{"zip4 added", func(a *easypost.Address) { a.Zip = "62701-1234" }, []string{"zip"}, true},
+		{"zip changed", func(a *easypost.Address) { a.Zip = "62702-1234" }, []string{"zip"}, false},
+		{"zip4 and street", func(a *easypost.Address) { a.Zip = "62701-1234"; a.Street1 = "1 Main Street" }, []string{"street1", "zip"}, false},
+		{"street2 added", func(a *easypost.Address) { a.Street2 = "Apt 2" }, []string{"street2"}, false},
+		{"name ignored", func(a *easypost.Address) { a.Name = "JANE Q DOE" }, nil, false},
+		{"every field", func(a *easypost.Address) {
+			a.Street1, a.Street2, a.City, a.State, a.Zip = "2 Oak Ave", "Unit 1", "Chicago", "IN", "60601"
+		}, []string{"street1", "street2", "city", "state", "zip"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verified := original
+			tt.verified(&verified)
+
+			changes := DiffAddress(&original, &verified)
+
+			var fields []string
+			for _, change := range changes {
+				fields = append(fields, change.Field)
+			}
+
+			if !reflect.DeepEqual(fields, tt.want) {
+				t.Errorf("changed fields = %v, want %v", fields, tt.want)
+			}
+
+			if got := IsZIP4Only(changes); got != tt.zip4Only {
+				t.Errorf("IsZIP4Only = %t, want %t", got, tt.zip4Only)
+			}
+		})
+	}
+}
+
+func TestIsZIP4Only(t *testing.T) {
+	tests := []struct {
+		name    string
+		changes []AddressChange
+		want    bool
+	}{
+		{"no changes", nil, false},
+		{"zip4 added", []AddressChange{{Field: "zip", Original: "62701", Verified: "62701-1234"}}, true},
+		{"zip4 added to padded zip", []AddressChange{{Field: "zip", Original: " 62701 ", Verified: "62701-1234"}}, true},
+		{"zip4 replaced", []AddressChange{{Field: "zip", Original: "62701-0000", Verified: "62701-1234"}}, false},
+		{"zip changed", []AddressChange{{Field: "zip", Original: "62701", Verified: "62702-1234"}}, false},
+		{"other field", []AddressChange{{Field: "city", Original: "Springfeld", Verified: "Springfield"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsZIP4Only(tt.changes); got != tt.want {
+				t.Errorf("IsZIP4Only = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,52 +1,129 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"strings"
 
 	"github.com/EasyPost/easypost-go/v4"
 	"github.com/debyltech/go-snipcart/snipcart"
 )
 
+// IsValidationWhitelisted reports whether the address is in the configured
+// whitelist of destinations where verification gives false negatives
 func IsValidationWhitelisted(address easypost.Address) bool {
-	// Romania
-	if strings.EqualFold(strings.ToLower(address.State), "if") && strings.EqualFold(strings.ToLower(address.Country), "ro") {
-		return true
+	return webhookConfig.VerificationWhitelisted(address.Country, address.State)
+}
+
+// verificationErrorFields maps the fields of EasyPost verification errors to
+// the Snipcart error key and message for the shipping address field
+var verificationErrorFields map[string]snipcart.ShippingError = map[string]snipcart.ShippingError{
+	"street1": {Key: "invalid_address_address1", Message: MsgInvalidAddressStreet},
+	"street2": {Key: "invalid_address_address2", Message: MsgInvalidAddressStreet},
+	"city":    {Key: "invalid_address_city", Message: MsgInvalidAddressCity},
+	"state":   {Key: "invalid_address_province", Message: MsgInvalidAddressProvince},
+	"zip":     {Key: "invalid_address_postal_code", Message: MsgInvalidAddressPostalCode},
+}
+
+// VerifyShippingAddress runs EasyPost's delivery verification, along with ZIP+4
+// verification for the US, on the address and returns the verified address, or
+// shipping errors keyed by field when the address is undeliverable
+func VerifyShippingAddress(ctx context.Context, easypostClient *easypost.Client, address *easypost.Address, locale *Locale) (*easypost.Address, *snipcart.ShippingErrors, error) {
+	verify := []string{"delivery"}
+	if strings.EqualFold(address.Country, "us") {
+		verify = append(verify, "zip4")
 	}
 
-	// Czechia
-	if strings.EqualFold(strings.ToLower(address.Country), "cz") {
-		return true
+	verified, err := easypostClient.CreateAddressWithContext(ctx, address, &easypost.CreateAddressOptions{
+		Verify: verify,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("error with address verification: %s", err.Error())
 	}
 
-	return false
-}
+	if verified.Verifications == nil {
+		return verified, nil, nil
+	}
+
+	var failed []*easypost.AddressVerification
+	if v := verified.Verifications.Delivery; v != nil && !v.Success {
+		failed = append(failed, v)
+	}
+	if v := verified.Verifications.ZIP4; v != nil && !v.Success && strings.EqualFold(address.Country, "us") {
+		failed = append(failed, v)
+	}
+
+	if len(failed) == 0 {
+		return verified, nil, nil
+	}
 
-func ValidateAddressFields(shippingAddress snipcart.Address, isProduction bool) *snipcart.ShippingErrors {
-	// Ensure the name of the shipping address has at least two words
-	shippingAddressName := strings.Split(shippingAddress.Name, " ")
-
-	if len(shippingAddressName) <= 1 {
-		return &snipcart.ShippingErrors{
-			Errors: []snipcart.ShippingError{
-				{
-					Key:     "invalid_address_name",
-					Message: "Shipping Address name must be at least two words (ex. 'Jon D', 'Jon Doe')",
-				},
-			},
+	shippingErrors := &snipcart.ShippingErrors{}
+	keys := make(map[string]bool)
+	for _, verification := range failed {
+		for _, fieldError := range verification.Errors {
+			DebugPrintf("address verification error %s: %s (%s)", fieldError.Field, fieldError.Message, fieldError.Code)
+
+			shippingError, ok := verificationErrorFields[fieldError.Field]
+			if !ok || keys[shippingError.Key] {
+				continue
+			}
+			keys[shippingError.Key] = true
+
+			shippingErrors.Errors = append(shippingErrors.Errors, snipcart.ShippingError{
+				Key:     shippingError.Key,
+				Message: locale.Text(shippingError.Message),
+			})
 		}
 	}
 
-	// Ensure the first name of the shipping address has more than two characters
-	if len(shippingAddressName[0]) <= 2 {
-		return &snipcart.ShippingErrors{
-			Errors: []snipcart.ShippingError{
-				{
-					Key:     "invalid_address_firstname_length",
-					Message: "Shipping Address first name must be longer than two characters (ex: 'Jon')",
-				},
-			},
+	// Verification failures without a field are for the address as a whole
+	if len(shippingErrors.Errors) == 0 {
+		shippingErrors.Errors = append(shippingErrors.Errors, snipcart.ShippingError{
+			Key:     "undeliverable_address",
+			Message: locale.Text(MsgUndeliverableAddress),
+		})
+	}
+
+	return verified, shippingErrors, nil
+}
+
+// ValidateAddressFields runs the configured address rules enabled for the
+// destination's zone, where production only rules are skipped outside of
+// production. It returns the errors of rules that reject the address, and the
+// carriers denied by rules limited to carriers
+func ValidateAddressFields(shippingAddress snipcart.Address, locale *Locale, isProduction bool) (*snipcart.ShippingErrors, []string) {
+	zone := webhookConfig.ZoneNameFor(shippingAddress.Country, shippingAddress.Province)
+
+	var shippingErrors []snipcart.ShippingError
+	var deniedCarriers []string
+	for i := range webhookConfig.AddressRules {
+		rule := &webhookConfig.AddressRules[i]
+		if !rule.Enabled(zone, isProduction) {
+			continue
 		}
+
+		check, ok := addressRules[rule.Name]
+		if !ok {
+			continue
+		}
+
+		ruleErrors := check(shippingAddress, rule, locale)
+		if len(ruleErrors) == 0 {
+			continue
+		}
+
+		if len(rule.Carriers) > 0 {
+			DebugPrintf("address rule %s denies carriers %v", rule.Name, rule.Carriers)
+			deniedCarriers = append(deniedCarriers, rule.Carriers...)
+			continue
+		}
+
+		shippingErrors = append(shippingErrors, ruleErrors...)
+	}
+
+	if len(shippingErrors) > 0 {
+		return &snipcart.ShippingErrors{Errors: shippingErrors}, deniedCarriers
 	}
 
-	return nil
+	return nil, deniedCarriers
 }
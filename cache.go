@@ -0,0 +1,278 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/EasyPost/easypost-go/v4"
+	"github.com/debyltech/go-snipcart-webhook/config"
+	"github.com/debyltech/go-snipcart/snipcart"
+)
+
+const (
+	ShipmentCacheMemory string = "memory"
+	ShipmentCacheFile   string = "file"
+	ShipmentCacheNone   string = "none"
+)
+
+// ShipmentCacheEntry is a previously created EasyPost shipment or order and
+// the rates that were quoted for it, or a corrected address that was suggested
+// to the customer
+type ShipmentCacheEntry struct {
+	ShipmentId       string            `json:"shipment_id,omitempty"`
+	OrderId          string            `json:"order_id,omitempty"`
+	Rates            []*easypost.Rate  `json:"rates"`
+	SuggestedAddress *easypost.Address `json:"suggested_address,omitempty"`
+	CreatedAt        time.Time         `json:"created_at"`
+}
+
+// ShipmentCache stores quoted shipments so that identical shipping rate
+// requests (i.e. Snipcart retries) reuse the existing shipment instead of
+// creating a duplicate. Get returns nil without an error when there is no
+// entry for the key or the entry has expired
+type ShipmentCache interface {
+	Get(key string) (*ShipmentCacheEntry, error)
+	Put(key string, entry *ShipmentCacheEntry) error
+}
+
+// NewShipmentCacheFromConfig creates the ShipmentCache selected in the config,
+// returning nil when caching is disabled
+func NewShipmentCacheFromConfig(c *config.Config) (ShipmentCache, error) {
+	switch c.ShipmentCache {
+	case ShipmentCacheMemory:
+		return NewMemoryShipmentCache(c.ShipmentCacheTTL), nil
+	case ShipmentCacheFile:
+		return NewFileShipmentCache(c.ShipmentCacheDir, c.ShipmentCacheTTL)
+	case ShipmentCacheNone, "":
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("unknown shipment cache '%s'", c.ShipmentCache)
+}
+
+// ShipmentCacheKey creates the cache key for an order from its token and a hash
+// of everything that affects the shipment: the shipping address, items, total
+// weight, and ship date, along with the prices, discounts, and currency that
+// decide the customs values and import VAT scheme
+func ShipmentCacheKey(order *WebhookOrder, shipDate time.Time) string {
+	type cacheKeyItem struct {
+		Name         string                 `json:"name"`
+		Price        float64                `json:"price"`
+		TotalPrice   float64                `json:"total_price"`
+		Quantity     int                    `json:"quantity"`
+		Weight       float64                `json:"weight"`
+		Length       float64                `json:"length"`
+		Width        float64                `json:"width"`
+		Height       float64                `json:"height"`
+		Shippable    bool                   `json:"shippable"`
+		CustomFields []snipcart.CustomField `json:"custom_fields"`
+	}
+
+	keyContent := struct {
+		Address     snipcart.Address  `json:"address"`
+		Items       []cacheKeyItem    `json:"items"`
+		ItemsTotal  float64           `json:"items_total"`
+		Discounts   []WebhookDiscount `json:"discounts"`
+		Currency    string            `json:"currency"`
+		TotalWeight float64           `json:"total_weight"`
+		ShipDate    string            `json:"ship_date"`
+	}{
+		Address:     order.ShippingAddress,
+		ItemsTotal:  order.ItemsTotal,
+		Discounts:   order.Discounts,
+		Currency:    order.Currency,
+		TotalWeight: order.TotalWeight,
+		ShipDate:    shipDate.Format(config.DateLayout),
+	}
+
+	for _, item := range order.Items {
+		keyContent.Items = append(keyContent.Items, cacheKeyItem{
+			Name:         item.Name,
+			Price:        item.Price,
+			TotalPrice:   item.TotalPrice,
+			Quantity:     item.Quantity,
+			Weight:       item.Weight,
+			Length:       item.Length,
+			Width:        item.Width,
+			Height:       item.Height,
+			Shippable:    item.Shippable,
+			CustomFields: item.CustomFields,
+		})
+	}
+
+	keyBytes, _ := json.Marshal(keyContent)
+	hash := sha256.Sum256(keyBytes)
+
+	return fmt.Sprintf("%s-%s", order.Token, hex.EncodeToString(hash[:]))
+}
+
+// CachedQuote returns the quote previously created for the key, or nil when
+// there is none or caching is disabled. Cache errors are logged rather than
+// returned since the quote can always be created again
+func CachedQuote(key string) *Quote {
+	if shipmentCache == nil {
+		return nil
+	}
+
+	entry, err := shipmentCache.Get(key)
+	if err != nil {
+		logJsonWithStatus(JsonLogStatusWarning, "shipment.cache", fmt.Sprintf("error with getting %s: %s", key, err.Error()))
+		return nil
+	}
+	if entry == nil {
+		return nil
+	}
+
+	DebugPrintf("reusing cached shipment %s order %s for %s", entry.ShipmentId, entry.OrderId, key)
+	return &Quote{
+		ShipmentId: entry.ShipmentId,
+		OrderId:    entry.OrderId,
+		Rates:      entry.Rates,
+	}
+}
+
+// CacheQuote stores the quote's shipment or order and its rates for the key
+func CacheQuote(key string, quote *Quote) {
+	if shipmentCache == nil {
+		return
+	}
+
+	err := shipmentCache.Put(key, &ShipmentCacheEntry{
+		ShipmentId: quote.ShipmentId,
+		OrderId:    quote.OrderId,
+		Rates:      quote.Rates,
+		CreatedAt:  time.Now(),
+	})
+	if err != nil {
+		logJsonWithStatus(JsonLogStatusWarning, "shipment.cache", fmt.Sprintf("error with putting %s: %s", key, err.Error()))
+	}
+}
+
+func isExpired(entry *ShipmentCacheEntry, ttl time.Duration) bool {
+	return ttl > 0 && time.Since(entry.CreatedAt) > ttl
+}
+
+// MemoryShipmentCache keeps entries in memory for the lifetime of the process
+// (or warm Lambda instance)
+type MemoryShipmentCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]*ShipmentCacheEntry
+}
+
+func NewMemoryShipmentCache(ttl time.Duration) *MemoryShipmentCache {
+	return &MemoryShipmentCache{
+		ttl:     ttl,
+		entries: make(map[string]*ShipmentCacheEntry),
+	}
+}
+
+func (m *MemoryShipmentCache) Get(key string) (*ShipmentCacheEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[key]
+	if !ok {
+		return nil, nil
+	}
+
+	if isExpired(entry, m.ttl) {
+		delete(m.entries, key)
+		return nil, nil
+	}
+
+	return entry, nil
+}
+
+func (m *MemoryShipmentCache) Put(key string, entry *ShipmentCacheEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Drop expired entries so long running servers do not grow unbounded
+	for k, v := range m.entries {
+		if isExpired(v, m.ttl) {
+			delete(m.entries, k)
+		}
+	}
+
+	m.entries[key] = entry
+	return nil
+}
+
+// FileShipmentCache keeps entries as JSON files in a directory so they survive
+// restarts and can be shared between processes on the same host
+type FileShipmentCache struct {
+	dir string
+	ttl time.Duration
+}
+
+func NewFileShipmentCache(dir string, ttl time.Duration) (*FileShipmentCache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("error with creating shipment cache directory: %s", err.Error())
+	}
+
+	return &FileShipmentCache{
+		dir: dir,
+		ttl: ttl,
+	}, nil
+}
+
+func (f *FileShipmentCache) path(key string) string {
+	// Keys contain the order token provided by the request, so hash it to get
+	// a safe file name
+	hash := sha256.Sum256([]byte(key))
+	return filepath.Join(f.dir, hex.EncodeToString(hash[:])+".json")
+}
+
+func (f *FileShipmentCache) Get(key string) (*ShipmentCacheEntry, error) {
+	entryBytes, err := os.ReadFile(f.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entry ShipmentCacheEntry
+	if err := json.Unmarshal(entryBytes, &entry); err != nil {
+		return nil, fmt.Errorf("error with shipment cache entry decode: %s", err.Error())
+	}
+
+	if isExpired(&entry, f.ttl) {
+		os.Remove(f.path(key))
+		return nil, nil
+	}
+
+	return &entry, nil
+}
+
+func (f *FileShipmentCache) Put(key string, entry *ShipmentCacheEntry) error {
+	entryBytes, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never see a partial entry
+	tmpFile, err := os.CreateTemp(f.dir, "entry-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(entryBytes); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile.Name(), f.path(key))
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/debyltech/go-snipcart/snipcart"
+)
+
+func TestShipmentCacheKey(t *testing.T) {
+	shipDate := time.Date(2026, time.November, 24, 0, 0, 0, 0, time.UTC)
+
+	order := func() *WebhookOrder {
+		return &WebhookOrder{
+			Order: snipcart.Order{
+				Token:    "token",
+				Currency: "eur",
+				Items:    []snipcart.Item{{Name: "Poster", Price: 80, TotalPrice: 160, Quantity: 2, Shippable: true}},
+			},
+			ItemsTotal: 160,
+		}
+	}
+
+	base := ShipmentCacheKey(order(), shipDate)
+
+	tests := []struct {
+		name   string
+		change func(o *WebhookOrder)
+	}{
+		{"item price", func(o *WebhookOrder) { o.Items[0].Price = 70 }},
+		{"item total price", func(o *WebhookOrder) { o.Items[0].TotalPrice = 140 }},
+		{"items total", func(o *WebhookOrder) { o.ItemsTotal = 140 }},
+		{"discount", func(o *WebhookOrder) { o.Discounts = []WebhookDiscount{{Code: "SAVE20", AmountSaved: 20}} }},
+		{"currency", func(o *WebhookOrder) { o.Currency = "gbp" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed := order()
+			tt.change(changed)
+
+			if ShipmentCacheKey(changed, shipDate) == base {
+				t.Errorf("key did not change with the %s", tt.name)
+			}
+		})
+	}
+
+	if ShipmentCacheKey(order(), shipDate) != base {
+		t.Error("key is not stable for the same order")
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 	ginadapter "github.com/awslabs/aws-lambda-go-api-proxy/gin"
 	"github.com/debyltech/go-snipcart-webhook/config"
+	"github.com/debyltech/go-snipcart/snipcart"
 	"github.com/gin-gonic/gin"
 )
 
@@ -28,10 +29,15 @@ const (
 )
 
 var (
+	ginEngine     *gin.Engine
 	ginLambda     *ginadapter.GinLambda
 	webhookConfig *config.Config
+	shipmentCache ShipmentCache
 
-	BuildVersion string             = "development"
+	BuildVersion string = "development"
+
+	// EUCountryVAT is the fallback standard VAT rate for countries without a
+	// rate configured in GSW_VAT_RATES_JSON or GSW_VAT_RATES_FILE
 	EUCountryVAT map[string]float64 = map[string]float64{
 		"at": 0.20,  // Austria
 		"be": 0.21,  // Belgium
@@ -158,6 +164,18 @@ func logJson(event string, message string) {
 	logJsonWithStatus(JsonLogStatusOk, event, message)
 }
 
+// ItemCustomField returns the value of the item's custom field with the given
+// name, or an empty string if the item does not have it
+func ItemCustomField(item *snipcart.Item, name string) string {
+	for _, f := range item.CustomFields {
+		if f.Name == name {
+			return f.Value
+		}
+	}
+
+	return ""
+}
+
 func IsInternational(country string) bool {
 	return strings.ToLower(country) != "us"
 }
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// AddressRule enables an address validation rule by name, optionally only for
+// destinations in the given zones or only in production. Rules limited to
+// carriers deny those carriers' rates instead of rejecting the address (i.e.
+// PO boxes for carriers that cannot deliver to them). MaxLength and Pattern
+// are settings for the rules that use them
+type AddressRule struct {
+	Name           string   `json:"name"`
+	Zones          []string `json:"zones,omitempty"`
+	Carriers       []string `json:"carriers,omitempty"`
+	ProductionOnly bool     `json:"production_only,omitempty"`
+
+	MaxLength int    `json:"max_length,omitempty"`
+	Pattern   string `json:"pattern,omitempty"`
+
+	PatternRegexp *regexp.Regexp `json:"-"`
+}
+
+// Enabled reports whether the rule applies to destinations in the zone
+func (r *AddressRule) Enabled(zone string, production bool) bool {
+	if r.ProductionOnly && !production {
+		return false
+	}
+
+	return len(r.Zones) == 0 || containsFold(r.Zones, zone)
+}
+
+func loadAddressRules(rulesJson string) ([]AddressRule, error) {
+	var rules []AddressRule
+	if err := json.Unmarshal([]byte(rulesJson), &rules); err != nil {
+		return nil, err
+	}
+
+	for i := range rules {
+		if rules[i].Name == "" {
+			return nil, fmt.Errorf("address rule %d must have a name", i)
+		}
+
+		if rules[i].Pattern != "" {
+			pattern, err := regexp.Compile(rules[i].Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern for address rule %s: %s", rules[i].Name, err.Error())
+			}
+			rules[i].PatternRegexp = pattern
+		}
+	}
+
+	return rules, nil
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// VerificationWhitelistEntry skips address verification for a country, or only
+// for the listed states of it, where verification gives false negatives
+type VerificationWhitelistEntry struct {
+	Country string   `json:"country"`
+	States  []string `json:"states,omitempty"`
+}
+
+// loadVerificationWhitelist parses the configured address verification
+// whitelist
+func loadVerificationWhitelist(whitelistJson string) ([]VerificationWhitelistEntry, error) {
+	var whitelist []VerificationWhitelistEntry
+	if err := json.Unmarshal([]byte(whitelistJson), &whitelist); err != nil {
+		return nil, err
+	}
+
+	for _, entry := range whitelist {
+		if entry.Country == "" {
+			return nil, fmt.Errorf("whitelist entry must have a country")
+		}
+	}
+
+	return whitelist, nil
+}
+
+// VerificationWhitelisted reports whether address verification is skipped for
+// the destination
+func (c *Config) VerificationWhitelisted(country string, state string) bool {
+	for _, entry := range c.VerificationWhitelist {
+		if !strings.EqualFold(entry.Country, country) {
+			continue
+		}
+
+		if len(entry.States) == 0 || containsFold(entry.States, state) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	// Embed the timezone database as it is not available in every runtime
+	_ "time/tzdata"
+)
+
+var weekdays map[string]time.Weekday = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// loadShipDays parses the weekdays we ship on, given as three letter names
+func loadShipDays(shipDays []string) (map[time.Weekday]bool, error) {
+	shipDaySet := make(map[time.Weekday]bool)
+
+	for _, day := range shipDays {
+		weekday, ok := weekdays[strings.ToLower(strings.TrimSpace(day))]
+		if !ok {
+			return nil, fmt.Errorf("invalid ship day '%s'", day)
+		}
+
+		shipDaySet[weekday] = true
+	}
+
+	if len(shipDaySet) == 0 {
+		return nil, fmt.Errorf("at least one ship day is required")
+	}
+
+	return shipDaySet, nil
+}
+
+// loadCutoff parses the daily order cutoff time, given as HH:MM, into the
+// duration after midnight
+func loadCutoff(cutoff string) (time.Duration, error) {
+	cutoffTime, err := time.Parse("15:04", cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cutoff time '%s': %s", cutoff, err.Error())
+	}
+
+	return time.Duration(cutoffTime.Hour())*time.Hour + time.Duration(cutoffTime.Minute())*time.Minute, nil
+}
+
+// loadHolidays parses the holiday dates into a set keyed by date
+func loadHolidays(holidays []string) (map[string]bool, error) {
+	holidayDates := make(map[string]bool)
+
+	for _, holiday := range holidays {
+		date, err := time.Parse(DateLayout, holiday)
+		if err != nil {
+			return nil, fmt.Errorf("invalid holiday '%s': %s", holiday, err.Error())
+		}
+
+		holidayDates[date.Format(DateLayout)] = true
+	}
+
+	return holidayDates, nil
+}
+
+// IsBusinessDay reports whether packages ship and are delivered on the date,
+// being a weekday that is not a holiday
+func (c *Config) IsBusinessDay(t time.Time) bool {
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return false
+	}
+
+	return !c.HolidayDates[t.Format(DateLayout)]
+}
+
+// IsShipDay reports whether we ship packages on the date, being one of the
+// configured ship days that is not a holiday
+func (c *Config) IsShipDay(t time.Time) bool {
+	if !c.ShipDaySet[t.Weekday()] {
+		return false
+	}
+
+	return !c.HolidayDates[t.Format(DateLayout)]
+}
+
+// AddBusinessDays returns the date the given number of business days after t
+func (c *Config) AddBusinessDays(t time.Time, days int) time.Time {
+	for days > 0 {
+		t = t.AddDate(0, 0, 1)
+		if c.IsBusinessDay(t) {
+			days--
+		}
+	}
+
+	return t
+}
+
+// NextBusinessDay returns t if it is a business day, otherwise the next
+// business day after it
+func (c *Config) NextBusinessDay(t time.Time) time.Time {
+	for !c.IsBusinessDay(t) {
+		t = t.AddDate(0, 0, 1)
+	}
+
+	return t
+}
+
+// ShipDate returns the date an order placed at the given time ships. Orders
+// placed after the daily cutoff are handled from the next day, and ship after
+// the configured handling time in ship days
+func (c *Config) ShipDate(orderedAt time.Time) time.Time {
+	local := orderedAt.In(c.Location)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, c.Location)
+
+	if local.Sub(midnight) >= c.Cutoff {
+		midnight = midnight.AddDate(0, 0, 1)
+	}
+
+	shipDate := midnight
+	for !c.IsShipDay(shipDate) {
+		shipDate = shipDate.AddDate(0, 0, 1)
+	}
+
+	for days := c.HandlingDays; days > 0; {
+		shipDate = shipDate.AddDate(0, 0, 1)
+		if c.IsShipDay(shipDate) {
+			days--
+		}
+	}
+
+	return shipDate
+}
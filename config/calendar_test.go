@@ -0,0 +1,65 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShipDate(t *testing.T) {
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shipDays, err := loadShipDays([]string{"mon", "tue", "wed", "thu", "fri"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	holidays, err := loadHolidays([]string{"2026-11-26"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cutoff, err := loadCutoff("14:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := func(day int, hour int, minute int) time.Time {
+		return time.Date(2026, time.November, day, hour, minute, 0, 0, location)
+	}
+
+	tests := []struct {
+		name         string
+		orderedAt    time.Time
+		handlingDays int
+		want         string
+	}{
+		{"before cutoff", at(23, 10, 0), 1, "2026-11-24"},
+		{"at cutoff", at(23, 14, 0), 1, "2026-11-25"},
+		{"after cutoff", at(23, 15, 0), 1, "2026-11-25"},
+		{"handling over holiday", at(25, 10, 0), 1, "2026-11-27"},
+		{"after cutoff before holiday", at(25, 15, 0), 1, "2026-11-30"},
+		{"weekend", at(28, 10, 0), 1, "2026-12-01"},
+		{"weekend without handling", at(28, 10, 0), 0, "2026-11-30"},
+		{"no handling before cutoff", at(24, 9, 0), 0, "2026-11-24"},
+		{"cutoff in config timezone", time.Date(2026, time.November, 23, 19, 30, 0, 0, time.UTC), 1, "2026-11-25"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{
+				HandlingDays: tt.handlingDays,
+				ShipDaySet:   shipDays,
+				Cutoff:       cutoff,
+				Location:     location,
+				HolidayDates: holidays,
+			}
+
+			if got := c.ShipDate(tt.orderedAt).Format(DateLayout); got != tt.want {
+				t.Errorf("ShipDate(%s) = %s, want %s", tt.orderedAt, got, tt.want)
+			}
+		})
+	}
+}
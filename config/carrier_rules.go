@@ -0,0 +1,72 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	CarrierRuleAllow string = "allow"
+	CarrierRuleDeny  string = "deny"
+)
+
+// CarrierRule allows or denies rates by carrier and service, optionally only
+// for destinations in the given zones or countries. Empty conditions match
+// everything
+type CarrierRule struct {
+	Action    string   `json:"action"`
+	Carrier   string   `json:"carrier,omitempty"`
+	Services  []string `json:"services,omitempty"`
+	Zones     []string `json:"zones,omitempty"`
+	Countries []string `json:"countries,omitempty"`
+}
+
+// Matches reports whether the rule applies to the rate
+func (r *CarrierRule) Matches(ctx *RateContext) bool {
+	if r.Carrier != "" && !containsFold([]string{r.Carrier}, ctx.Carrier) {
+		return false
+	}
+
+	if len(r.Services) > 0 && !containsFold(r.Services, ctx.Service) {
+		return false
+	}
+
+	if len(r.Zones) > 0 && !containsFold(r.Zones, ctx.Zone) {
+		return false
+	}
+
+	return len(r.Countries) == 0 || containsFold(r.Countries, ctx.Country)
+}
+
+func loadCarrierRules(rulesJson string) ([]CarrierRule, error) {
+	var rules []CarrierRule
+	if err := json.Unmarshal([]byte(rulesJson), &rules); err != nil {
+		return nil, err
+	}
+
+	for i, rule := range rules {
+		if rule.Action != CarrierRuleAllow && rule.Action != CarrierRuleDeny {
+			return nil, fmt.Errorf("unknown action '%s' for carrier rule %d", rule.Action, i)
+		}
+	}
+
+	return rules, nil
+}
+
+// RateAllowed checks the rate's carrier and service against the carrier rules
+// in order, where the first matching rule decides. Rates matching no rule fall
+// back to the allowed carriers of the zone. Carriers denied for the address
+// are never allowed
+func (c *Config) RateAllowed(ctx *RateContext, zone *Zone) bool {
+	if containsFold(ctx.DeniedCarriers, ctx.Carrier) {
+		return false
+	}
+
+	for i := range c.CarrierRules {
+		if c.CarrierRules[i].Matches(ctx) {
+			return c.CarrierRules[i].Action == CarrierRuleAllow
+		}
+	}
+
+	return c.CarrierAllowedInZone(ctx.Carrier, zone)
+}
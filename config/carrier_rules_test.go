@@ -0,0 +1,50 @@
+package config
+
+import "testing"
+
+func TestRateAllowed(t *testing.T) {
+	domestic := &Zone{Name: ZoneDomestic, Countries: []string{"US"}}
+	eu := &Zone{Name: ZoneEU, Countries: EUCountries, AllowedCarriers: []string{"DHLExpress", "UPS"}}
+
+	c := &Config{
+		AllowedCarriers: "USPS,UPS",
+		CarrierRules: []CarrierRule{
+			{Action: CarrierRuleDeny, Carrier: "USPS", Services: []string{"GroundAdvantage"}, Countries: []string{"PR"}},
+			{Action: CarrierRuleAllow, Carrier: "USPS", Services: []string{"GroundAdvantage", "Priority"}},
+			{Action: CarrierRuleDeny, Carrier: "USPS"},
+			{Action: CarrierRuleAllow, Carrier: "FedEx", Zones: []string{ZoneDomestic}},
+		},
+	}
+
+	rate := func(country string, zone *Zone, carrier string, service string, denied ...string) *RateContext {
+		return &RateContext{Zone: zone.Name, Country: country, Carrier: carrier, Service: service, DeniedCarriers: denied}
+	}
+
+	tests := []struct {
+		name string
+		ctx  *RateContext
+		zone *Zone
+		want bool
+	}{
+		{"allowed service", rate("US", domestic, "USPS", "Priority"), domestic, true},
+		{"service case", rate("US", domestic, "usps", "priority"), domestic, true},
+		{"other service denied", rate("US", domestic, "USPS", "Express"), domestic, false},
+		{"first matching rule decides", rate("PR", domestic, "USPS", "GroundAdvantage"), domestic, false},
+		{"rule limited to zone", rate("US", domestic, "FedEx", "Ground"), domestic, true},
+		{"rule outside zone", rate("DE", eu, "FedEx", "International"), eu, false},
+		{"global allowed carriers", rate("US", domestic, "UPS", "Ground"), domestic, true},
+		{"global carriers not allowed", rate("US", domestic, "DHLExpress", "Worldwide"), domestic, false},
+		{"zone allowed carriers", rate("DE", eu, "DHLExpress", "Worldwide"), eu, true},
+		{"denied for address", rate("US", domestic, "UPS", "Ground", "UPS"), domestic, false},
+		{"denied before rules", rate("US", domestic, "USPS", "Priority", "usps"), domestic, false},
+		{"no zone", &RateContext{Country: "ZZ", Carrier: "UPS"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.RateAllowed(tt.ctx, tt.zone); got != tt.want {
+				t.Errorf("RateAllowed(%s %s) = %t, want %t", tt.ctx.Carrier, tt.ctx.Service, got, tt.want)
+			}
+		})
+	}
+}
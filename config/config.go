@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/EasyPost/easypost-go/v4"
 	"github.com/aws/aws-secretsmanager-caching-go/secretcache"
@@ -29,12 +30,74 @@ type Config struct {
 	DefaultParcelJson string `env:"GSW_PARCEL_JSON,required"`
 	DefaultParcel     *easypost.Parcel
 
+	BoxesJson string `env:"GSW_BOXES_JSON" envDefault:"[]"`
+	Boxes     []Box
+
+	// Packaging weights are in grams, like Snipcart item weights. The
+	// ParcelTareWeight is added to the default parcel, and ItemPackagingWeight
+	// for every unit of an item
+	ParcelTareWeight    float64 `env:"GSW_PARCEL_TARE_WEIGHT" envDefault:"0"`
+	ItemPackagingWeight float64 `env:"GSW_ITEM_PACKAGING_WEIGHT" envDefault:"0"`
+
 	AllowedCarriers string `env:"GSW_ALLOWED_CARRIERS" envDefault:"USPS"`
 
+	CarrierRulesJson string `env:"GSW_CARRIER_RULES_JSON" envDefault:"[]"`
+	CarrierRules     []CarrierRule
+
+	VerifyAddress             bool   `env:"GSW_VERIFY_ADDRESS" envDefault:"false"`
+	AddressSuggestions        bool   `env:"GSW_ADDRESS_SUGGESTIONS" envDefault:"false"`
+	SanitizeAddress           bool   `env:"GSW_SANITIZE_ADDRESS" envDefault:"true"`
+	VerificationWhitelistJson string `env:"GSW_VERIFY_WHITELIST_JSON" envDefault:"[{\"country\":\"RO\",\"states\":[\"IF\"]},{\"country\":\"CZ\"}]"`
+	VerificationWhitelist     []VerificationWhitelistEntry
+
+	AddressRulesJson string `env:"GSW_ADDRESS_RULES_JSON" envDefault:"[{\"name\":\"name_format\"},{\"name\":\"required_fields\"},{\"name\":\"postal_code\"}]"`
+	AddressRules     []AddressRule
+
+	ZonesJson string `env:"GSW_ZONES_JSON" envDefault:"[]"`
+	Zones     []Zone
+
 	ShippingDiscount int `env:"GSW_SHIP_DISCOUNT" envDefault:"0"`
 
+	RateCuration string `env:"GSW_RATE_CURATION" envDefault:"all"`
+	RateLimit    int    `env:"GSW_RATE_LIMIT" envDefault:"0"`
+	RateDedupe   bool   `env:"GSW_RATE_DEDUPE" envDefault:"false"`
+	RateLabels   bool   `env:"GSW_RATE_LABELS" envDefault:"false"`
+
+	ShippingRulesJson string `env:"GSW_SHIPPING_RULES_JSON" envDefault:"[]"`
+	ShippingRules     []ShippingRule
+
+	HandlingDays int      `env:"GSW_HANDLING_DAYS" envDefault:"1"`
+	ShipDays     []string `env:"GSW_SHIP_DAYS" envSeparator:"," envDefault:"mon,tue,wed,thu,fri"`
+	ShipDaySet   map[time.Weekday]bool
+	CutoffTime   string `env:"GSW_CUTOFF_TIME" envDefault:"14:00"`
+	Cutoff       time.Duration
+	Timezone     string `env:"GSW_TIMEZONE" envDefault:"America/New_York"`
+	Location     *time.Location
+	Holidays     []string `env:"GSW_HOLIDAYS" envSeparator:","`
+	HolidayDates map[string]bool
+
+	RateTimeout       time.Duration `env:"GSW_RATE_TIMEOUT" envDefault:"10s"`
+	FallbackRatesJson string        `env:"GSW_FALLBACK_RATES_JSON" envDefault:"{}"`
+	FallbackRates     map[string][]FallbackRate
+
+	ShipmentCache    string        `env:"GSW_SHIPMENT_CACHE" envDefault:"memory"`
+	ShipmentCacheDir string        `env:"GSW_SHIPMENT_CACHE_DIR" envDefault:"/tmp/go-snipcart-webhook"`
+	ShipmentCacheTTL time.Duration `env:"GSW_SHIPMENT_CACHE_TTL" envDefault:"24h"`
+
+	SalesTaxJson string `env:"GSW_SALES_TAX_JSON" envDefault:"[]"`
+	SalesTax     []SalesTaxRule
+
+	VATRatesJson  string `env:"GSW_VAT_RATES_JSON" envDefault:"{}"`
+	VATRatesFile  string `env:"GSW_VAT_RATES_FILE"`
+	VATRates      map[string][]VATRate
+	VATOnShipping bool `env:"GSW_VAT_SHIPPING" envDefault:"true"`
+
+	ExchangeRatesJson string `env:"GSW_EXCHANGE_RATES_JSON"`
+	ExchangeRates     map[string]float64
+
 	VAT             string `env:"GSW_VAT,unset"`
 	IOSS            string `env:"GSW_IOSS,unset"`
+	VOEC            string `env:"GSW_VOEC,unset"`
 	CustomsVerifier string `env:"GSW_CUSTOMSVERIFIER,unset"`
 }
 
@@ -80,6 +143,88 @@ func NewConfigFromEnv(useAwsSms bool) (*Config, error) {
 	}
 	config.DefaultParcel = &defaultParcel
 
+	boxes, err := loadBoxes(config.BoxesJson)
+	if err != nil {
+		return &config, fmt.Errorf("issue with boxes: %s", err.Error())
+	}
+	config.Boxes = boxes
+
+	zones, err := loadZones(config.ZonesJson)
+	if err != nil {
+		return &config, fmt.Errorf("issue with zones: %s", err.Error())
+	}
+	config.Zones = zones
+
+	verificationWhitelist, err := loadVerificationWhitelist(config.VerificationWhitelistJson)
+	if err != nil {
+		return &config, fmt.Errorf("issue with verification whitelist: %s", err.Error())
+	}
+	config.VerificationWhitelist = verificationWhitelist
+
+	addressRules, err := loadAddressRules(config.AddressRulesJson)
+	if err != nil {
+		return &config, fmt.Errorf("issue with address rules: %s", err.Error())
+	}
+	config.AddressRules = addressRules
+
+	holidayDates, err := loadHolidays(config.Holidays)
+	if err != nil {
+		return &config, fmt.Errorf("issue with holidays: %s", err.Error())
+	}
+	config.HolidayDates = holidayDates
+
+	shipDaySet, err := loadShipDays(config.ShipDays)
+	if err != nil {
+		return &config, fmt.Errorf("issue with ship days: %s", err.Error())
+	}
+	config.ShipDaySet = shipDaySet
+
+	cutoff, err := loadCutoff(config.CutoffTime)
+	if err != nil {
+		return &config, fmt.Errorf("issue with cutoff: %s", err.Error())
+	}
+	config.Cutoff = cutoff
+
+	location, err := time.LoadLocation(config.Timezone)
+	if err != nil {
+		return &config, fmt.Errorf("issue with timezone: %s", err.Error())
+	}
+	config.Location = location
+
+	fallbackRates, err := loadFallbackRates(config.FallbackRatesJson)
+	if err != nil {
+		return &config, fmt.Errorf("issue with fallback rates: %s", err.Error())
+	}
+	config.FallbackRates = fallbackRates
+
+	carrierRules, err := loadCarrierRules(config.CarrierRulesJson)
+	if err != nil {
+		return &config, fmt.Errorf("issue with carrier rules: %s", err.Error())
+	}
+	config.CarrierRules = carrierRules
+
+	shippingRules, err := loadShippingRules(config.ShippingRulesJson)
+	if err != nil {
+		return &config, fmt.Errorf("issue with shipping rules: %s", err.Error())
+	}
+	config.ShippingRules = shippingRules
+
+	if err := json.Unmarshal([]byte(config.SalesTaxJson), &config.SalesTax); err != nil {
+		return &config, fmt.Errorf("issue with sales tax unmarshal: %s", err.Error())
+	}
+
+	vatRates, err := loadVATRates(config.VATRatesFile, config.VATRatesJson)
+	if err != nil {
+		return &config, fmt.Errorf("issue with VAT rates: %s", err.Error())
+	}
+	config.VATRates = vatRates
+
+	exchangeRates, err := loadExchangeRates(config.ExchangeRatesJson)
+	if err != nil {
+		return &config, fmt.Errorf("issue with exchange rates: %s", err.Error())
+	}
+	config.ExchangeRates = exchangeRates
+
 	if useAwsSms {
 		secretCache, err := secretcache.New()
 		if err != nil {
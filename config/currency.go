@@ -0,0 +1,63 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// DefaultExchangeRates are the units of each currency per US dollar, used when
+// GSW_EXCHANGE_RATES_JSON is not set. These only need to be accurate enough for
+// comparing order values against import thresholds
+var DefaultExchangeRates map[string]float64 = map[string]float64{
+	"USD": 1.00,
+	"CAD": 1.36,
+	"CHF": 0.88,
+	"EUR": 0.92,
+	"GBP": 0.79,
+	"NOK": 10.70,
+}
+
+func loadExchangeRates(ratesJson string) (map[string]float64, error) {
+	if ratesJson == "" {
+		return DefaultExchangeRates, nil
+	}
+
+	var rawRates map[string]float64
+	if err := json.Unmarshal([]byte(ratesJson), &rawRates); err != nil {
+		return nil, err
+	}
+
+	rates := make(map[string]float64)
+	for currency, rate := range rawRates {
+		if rate <= 0 {
+			return nil, fmt.Errorf("invalid exchange rate %f for %s", rate, currency)
+		}
+		rates[strings.ToUpper(currency)] = rate
+	}
+
+	return rates, nil
+}
+
+// ConvertCurrency converts the amount between two currencies using the
+// configured exchange rates
+func (c *Config) ConvertCurrency(amount float64, from string, to string) (float64, error) {
+	from = strings.ToUpper(from)
+	to = strings.ToUpper(to)
+
+	if from == to {
+		return amount, nil
+	}
+
+	fromRate, ok := c.ExchangeRates[from]
+	if !ok {
+		return 0, fmt.Errorf("no exchange rate for %s", from)
+	}
+
+	toRate, ok := c.ExchangeRates[to]
+	if !ok {
+		return 0, fmt.Errorf("no exchange rate for %s", to)
+	}
+
+	return amount / fromRate * toRate, nil
+}
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// FallbackRate is a flat rate returned to Snipcart for a zone when live
+// rating with EasyPost fails, so checkout is never blocked on the carrier API
+type FallbackRate struct {
+	Description string  `json:"description"`
+	Cost        float64 `json:"cost"`
+}
+
+// loadFallbackRates parses the fallback rates keyed by zone name
+func loadFallbackRates(ratesJson string) (map[string][]FallbackRate, error) {
+	var rates map[string][]FallbackRate
+	if err := json.Unmarshal([]byte(ratesJson), &rates); err != nil {
+		return nil, err
+	}
+
+	for zone, zoneRates := range rates {
+		for _, rate := range zoneRates {
+			if rate.Description == "" || rate.Cost < 0 {
+				return nil, fmt.Errorf("fallback rates for zone '%s' need a description and a cost", zone)
+			}
+		}
+	}
+
+	return rates, nil
+}
+
+// FallbackRatesFor returns the fallback rates of the zone
+func (c *Config) FallbackRatesFor(zone string) []FallbackRate {
+	return c.FallbackRates[zone]
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Box is a shipping box that order items are packed into, with dimensions in
+// inches as EasyPost expects and weights in grams like every other packaging
+// weight. TareWeight is the weight of the empty box with its filler and
+// packing materials, and MaxWeight includes it
+type Box struct {
+	Name       string  `json:"name"`
+	Length     float64 `json:"length"`
+	Width      float64 `json:"width"`
+	Height     float64 `json:"height"`
+	MaxWeight  float64 `json:"max_weight"`
+	TareWeight float64 `json:"tare_weight"`
+}
+
+func (b *Box) Volume() float64 {
+	return b.Length * b.Width * b.Height
+}
+
+// loadBoxes parses the configured boxes, sorted from smallest to largest
+func loadBoxes(boxesJson string) ([]Box, error) {
+	var boxes []Box
+	if err := json.Unmarshal([]byte(boxesJson), &boxes); err != nil {
+		return nil, err
+	}
+
+	for _, box := range boxes {
+		if box.Length <= 0 || box.Width <= 0 || box.Height <= 0 {
+			return nil, fmt.Errorf("box '%s' must have a length, width, and height", box.Name)
+		}
+
+		if box.MaxWeight > 0 && box.TareWeight >= box.MaxWeight {
+			return nil, fmt.Errorf("box '%s' tare weight must be less than its max weight", box.Name)
+		}
+	}
+
+	sort.SliceStable(boxes, func(i, j int) bool {
+		return boxes[i].Volume() < boxes[j].Volume()
+	})
+
+	return boxes, nil
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"strings"
+)
+
+// SalesTaxRule is the sales tax configuration of a US state where there is
+// nexus, with optional rates overriding the state rate by ZIP code prefix
+type SalesTaxRule struct {
+	State       string             `json:"state"`
+	Name        string             `json:"name,omitempty"`
+	Rate        float64            `json:"rate"`
+	ZipRates    map[string]float64 `json:"zip_rates,omitempty"`
+	TaxShipping bool               `json:"tax_shipping"`
+}
+
+// SalesTaxFor returns the sales tax rule for the US state, or nil when there is
+// no nexus in the state
+func (c *Config) SalesTaxFor(state string) *SalesTaxRule {
+	for i, rule := range c.SalesTax {
+		if strings.EqualFold(rule.State, state) {
+			return &c.SalesTax[i]
+		}
+	}
+
+	return nil
+}
+
+// RateFor returns the rate of the longest ZIP code prefix matching zip, or the
+// state rate if none match
+func (r *SalesTaxRule) RateFor(zip string) float64 {
+	rate := r.Rate
+	longestPrefix := 0
+
+	for prefix, prefixRate := range r.ZipRates {
+		if strings.HasPrefix(zip, prefix) && len(prefix) > longestPrefix {
+			rate = prefixRate
+			longestPrefix = len(prefix)
+		}
+	}
+
+	return rate
+}
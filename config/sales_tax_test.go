@@ -0,0 +1,65 @@
+package config
+
+import "testing"
+
+func TestSalesTaxRuleRateFor(t *testing.T) {
+	rule := &SalesTaxRule{
+		State: "NY",
+		Rate:  0.04,
+		ZipRates: map[string]float64{
+			"1":     0.07,
+			"100":   0.08875,
+			"10001": 0.09,
+			"14":    0.08,
+		},
+	}
+
+	tests := []struct {
+		zip  string
+		want float64
+	}{
+		{"12207", 0.07},
+		{"10002", 0.08875},
+		{"10001", 0.09},
+		{"10001-1234", 0.09},
+		{"14604", 0.08},
+		{"06103", 0.04},
+		{"", 0.04},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.zip, func(t *testing.T) {
+			if got := rule.RateFor(tt.zip); got != tt.want {
+				t.Errorf("RateFor(%q) = %f, want %f", tt.zip, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSalesTaxFor(t *testing.T) {
+	c := &Config{SalesTax: []SalesTaxRule{{State: "NY", Rate: 0.04}, {State: "nj", Rate: 0.06625}}}
+
+	tests := []struct {
+		state string
+		want  float64
+		found bool
+	}{
+		{"NY", 0.04, true},
+		{"ny", 0.04, true},
+		{"NJ", 0.06625, true},
+		{"NH", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.state, func(t *testing.T) {
+			rule := c.SalesTaxFor(tt.state)
+			if (rule != nil) != tt.found {
+				t.Fatalf("SalesTaxFor(%q) = %+v, want found %t", tt.state, rule, tt.found)
+			}
+
+			if rule != nil && rule.Rate != tt.want {
+				t.Errorf("rate = %f, want %f", rule.Rate, tt.want)
+			}
+		})
+	}
+}
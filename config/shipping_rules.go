@@ -0,0 +1,110 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	ShippingRuleFreeShipping string = "free_shipping"
+	ShippingRuleFlatRate     string = "flat_rate"
+	ShippingRuleAdjust       string = "adjust"
+)
+
+// RateContext is the order and rate that shipping rules are matched against.
+// DeniedCarriers are the carriers that cannot ship to the address
+type RateContext struct {
+	Zone           string
+	Country        string
+	State          string
+	Carrier        string
+	Service        string
+	Subtotal       float64
+	Coupons        []string
+	DeniedCarriers []string
+}
+
+// ShippingRule is a declarative change to the cost of the rates returned to
+// Snipcart. Rules only apply to rates matching all of the rule's conditions,
+// where empty conditions match everything:
+//   - free_shipping sets the cost to zero
+//   - flat_rate sets the cost to Cost
+//   - adjust changes the cost by Percent (i.e. 10 for a 10% markup, -20 for a
+//     20% discount) and then by Amount
+type ShippingRule struct {
+	Type string `json:"type"`
+
+	Zones       []string `json:"zones,omitempty"`
+	Countries   []string `json:"countries,omitempty"`
+	Carriers    []string `json:"carriers,omitempty"`
+	Services    []string `json:"services,omitempty"`
+	Coupons     []string `json:"coupons,omitempty"`
+	MinSubtotal float64  `json:"min_subtotal,omitempty"`
+
+	Cost    float64 `json:"cost,omitempty"`
+	Percent float64 `json:"percent,omitempty"`
+	Amount  float64 `json:"amount,omitempty"`
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Matches reports whether the rule applies to the rate
+func (r *ShippingRule) Matches(ctx *RateContext) bool {
+	if len(r.Zones) > 0 && !containsFold(r.Zones, ctx.Zone) {
+		return false
+	}
+
+	if len(r.Countries) > 0 && !containsFold(r.Countries, ctx.Country) {
+		return false
+	}
+
+	if len(r.Carriers) > 0 && !containsFold(r.Carriers, ctx.Carrier) {
+		return false
+	}
+
+	if len(r.Services) > 0 && !containsFold(r.Services, ctx.Service) {
+		return false
+	}
+
+	if len(r.Coupons) > 0 {
+		couponUsed := false
+		for _, coupon := range ctx.Coupons {
+			if containsFold(r.Coupons, coupon) {
+				couponUsed = true
+				break
+			}
+		}
+
+		if !couponUsed {
+			return false
+		}
+	}
+
+	return ctx.Subtotal >= r.MinSubtotal
+}
+
+func loadShippingRules(rulesJson string) ([]ShippingRule, error) {
+	var rules []ShippingRule
+	if err := json.Unmarshal([]byte(rulesJson), &rules); err != nil {
+		return nil, err
+	}
+
+	for i, rule := range rules {
+		switch rule.Type {
+		case ShippingRuleFreeShipping, ShippingRuleFlatRate, ShippingRuleAdjust:
+		default:
+			return nil, fmt.Errorf("unknown type '%s' for shipping rule %d", rule.Type, i)
+		}
+	}
+
+	return rules, nil
+}
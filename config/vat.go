@@ -0,0 +1,114 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const DateLayout string = "2006-01-02"
+
+// Date is a calendar date formatted as YYYY-MM-DD in JSON
+type Date struct {
+	time.Time
+}
+
+func (d *Date) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	t, err := time.Parse(DateLayout, s)
+	if err != nil {
+		return err
+	}
+
+	d.Time = t
+	return nil
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.Format(DateLayout))
+}
+
+// VATRate is a country's standard VAT rate effective from the given date, with
+// optional reduced rates keyed by product tax category
+type VATRate struct {
+	EffectiveFrom Date               `json:"from"`
+	Rate          float64            `json:"rate"`
+	Categories    map[string]float64 `json:"categories,omitempty"`
+}
+
+// CategoryRate returns the rate for the tax category, or the standard rate if
+// the category has no reduced rate
+func (r *VATRate) CategoryRate(category string) float64 {
+	if rate, ok := r.Categories[strings.ToLower(category)]; ok {
+		return rate
+	}
+
+	return r.Rate
+}
+
+// loadVATRates loads the VAT rate table from the file if set, otherwise from
+// the JSON string, keyed by lowercase country code and sorted by effective date
+func loadVATRates(filePath string, ratesJson string) (map[string][]VATRate, error) {
+	ratesBytes := []byte(ratesJson)
+	if filePath != "" {
+		var err error
+		ratesBytes, err = os.ReadFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var rawRates map[string][]VATRate
+	if err := json.Unmarshal(ratesBytes, &rawRates); err != nil {
+		return nil, err
+	}
+
+	rates := make(map[string][]VATRate)
+	for country, countryRates := range rawRates {
+		sort.Slice(countryRates, func(i, j int) bool {
+			return countryRates[i].EffectiveFrom.Before(countryRates[j].EffectiveFrom.Time)
+		})
+
+		for i, rate := range countryRates {
+			if rate.Rate < 0 || rate.Rate >= 1 {
+				return nil, fmt.Errorf("invalid VAT rate %f for %s", rate.Rate, country)
+			}
+
+			categories := make(map[string]float64)
+			for category, categoryRate := range rate.Categories {
+				if categoryRate < 0 || categoryRate >= 1 {
+					return nil, fmt.Errorf("invalid VAT rate %f for %s category %s", categoryRate, country, category)
+				}
+				categories[strings.ToLower(category)] = categoryRate
+			}
+			countryRates[i].Categories = categories
+		}
+
+		rates[strings.ToLower(country)] = countryRates
+	}
+
+	return rates, nil
+}
+
+// VATRateAt returns the VAT rate of the country in effect at the given time,
+// and false if there is no configured rate in effect
+func (c *Config) VATRateAt(country string, at time.Time) (*VATRate, bool) {
+	countryRates := c.VATRates[strings.ToLower(country)]
+
+	// Rates are sorted by effective date, so the last one that has started is
+	// the one in effect
+	for i := len(countryRates) - 1; i >= 0; i-- {
+		if !at.Before(countryRates[i].EffectiveFrom.Time) {
+			return &countryRates[i], true
+		}
+	}
+
+	return nil, false
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	ZoneDomestic    string = "domestic"
+	ZoneCanada      string = "canada"
+	ZoneEU          string = "eu"
+	ZoneUK          string = "uk"
+	ZoneRestOfWorld string = "rest_of_world"
+
+	// ZoneAnyCountry matches every country when used in a zone's countries
+	ZoneAnyCountry string = "*"
+)
+
+// ZoneCustoms overrides the customs settings of international shipments to a
+// zone, using EasyPost's values (i.e. contents type "gift")
+type ZoneCustoms struct {
+	ContentsType      string `json:"contents_type,omitempty"`
+	RestrictionType   string `json:"restriction_type,omitempty"`
+	NonDeliveryOption string `json:"non_delivery_option,omitempty"`
+	EELPFC            string `json:"eel_pfc,omitempty"`
+}
+
+// Zone is a named group of destinations that rate filtering, pricing rules,
+// customs settings, and the carrier allowlist can reference. States limit the
+// zone to those states of its countries
+type Zone struct {
+	Name            string       `json:"name"`
+	Countries       []string     `json:"countries"`
+	States          []string     `json:"states,omitempty"`
+	AllowedCarriers []string     `json:"allowed_carriers,omitempty"`
+	Customs         *ZoneCustoms `json:"customs,omitempty"`
+}
+
+var (
+	EUCountries []string = []string{
+		"AT", "BE", "BG", "HR", "CY", "CZ", "DK", "EE", "FI", "FR", "DE", "GR",
+		"HU", "IE", "IT", "LV", "LT", "LU", "MT", "NL", "PL", "PT", "RO", "SK",
+		"SI", "ES", "SE",
+	}
+
+	// DefaultZones are matched after any configured zones, and a configured zone
+	// with the same name replaces the default one
+	DefaultZones []Zone = []Zone{
+		{Name: ZoneDomestic, Countries: []string{"US"}},
+		{Name: ZoneCanada, Countries: []string{"CA"}},
+		{Name: ZoneEU, Countries: EUCountries},
+		{Name: ZoneUK, Countries: []string{"GB"}},
+		{Name: ZoneRestOfWorld, Countries: []string{ZoneAnyCountry}},
+	}
+)
+
+// Matches reports whether the destination is in the zone
+func (z *Zone) Matches(country string, state string) bool {
+	if !containsFold(z.Countries, country) && !containsFold(z.Countries, ZoneAnyCountry) {
+		return false
+	}
+
+	return len(z.States) == 0 || containsFold(z.States, state)
+}
+
+// loadZones parses the configured zones and appends the default zones they do
+// not replace
+func loadZones(zonesJson string) ([]Zone, error) {
+	var zones []Zone
+	if err := json.Unmarshal([]byte(zonesJson), &zones); err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool)
+	for _, zone := range zones {
+		if zone.Name == "" {
+			return nil, fmt.Errorf("zone must have a name")
+		}
+
+		if names[zone.Name] {
+			return nil, fmt.Errorf("zone '%s' is defined more than once", zone.Name)
+		}
+		names[zone.Name] = true
+	}
+
+	for _, zone := range DefaultZones {
+		if !names[zone.Name] {
+			zones = append(zones, zone)
+		}
+	}
+
+	return zones, nil
+}
+
+// ZoneFor returns the first zone the destination is in, or nil if it is in
+// none of them
+func (c *Config) ZoneFor(country string, state string) *Zone {
+	for i := range c.Zones {
+		if c.Zones[i].Matches(country, state) {
+			return &c.Zones[i]
+		}
+	}
+
+	return nil
+}
+
+// ZoneNameFor returns the name of the zone the destination is in, or an empty
+// string if it is in none of them
+func (c *Config) ZoneNameFor(country string, state string) string {
+	if zone := c.ZoneFor(country, state); zone != nil {
+		return zone.Name
+	}
+
+	return ""
+}
+
+// CarrierAllowedInZone checks the carrier against the zone's allowed carriers,
+// or against the global allowed carriers when the zone does not set any
+func (c *Config) CarrierAllowedInZone(carrier string, zone *Zone) bool {
+	if zone != nil && len(zone.AllowedCarriers) > 0 {
+		return containsFold(zone.AllowedCarriers, carrier)
+	}
+
+	return c.CarrierAllowed(carrier)
+}
+
+// CarriersInZone returns the zone's allowed carriers, or the global allowed
+// carriers when the zone does not set any
+func (c *Config) CarriersInZone(zone *Zone) []string {
+	if zone != nil && len(zone.AllowedCarriers) > 0 {
+		return zone.AllowedCarriers
+	}
+
+	return strings.Split(c.AllowedCarriers, ",")
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/debyltech/go-snipcart-webhook/config"
+)
+
+const (
+	RateCurationAll      string = "all"
+	RateCurationCheapest string = "cheapest"
+	RateCurationTiers    string = "tiers"
+
+	// Highest delivery days of the express and standard tiers, anything slower
+	// or without an estimate is economy
+	TierExpressMaxDays  int = 2
+	TierStandardMaxDays int = 5
+)
+
+type RateTier string
+
+const (
+	RateTierExpress  RateTier = "Express"
+	RateTierStandard RateTier = "Standard"
+	RateTierEconomy  RateTier = "Economy"
+)
+
+// RateTierFor buckets the rate by its delivery days
+func RateTierFor(rate *ShippingRate) RateTier {
+	switch {
+	case rate.deliveryDays <= 0:
+		return RateTierEconomy
+	case rate.deliveryDays <= TierExpressMaxDays:
+		return RateTierExpress
+	case rate.deliveryDays <= TierStandardMaxDays:
+		return RateTierStandard
+	}
+
+	return RateTierEconomy
+}
+
+// CurateRates limits the rates, which must be sorted by cost, to those worth
+// showing the customer according to the configured curation mode:
+//   - all keeps every rate
+//   - cheapest keeps the cheapest rates up to the configured limit
+//   - tiers keeps the cheapest express, standard, and economy rate
+//
+// Rates with equal cost can be deduplicated, keeping the fastest, and the
+// cheapest and fastest rates can be labeled in the customer's language
+func CurateRates(c *config.Config, rates []ShippingRate, locale *Locale) ([]ShippingRate, error) {
+	curated := rates
+
+	if c.RateDedupe {
+		curated = dedupeRates(curated)
+	}
+
+	switch c.RateCuration {
+	case RateCurationAll, "":
+	case RateCurationCheapest:
+		// Rates are already sorted by cost, so the limit below keeps the cheapest
+	case RateCurationTiers:
+		curated = tierRates(curated, locale)
+	default:
+		return nil, fmt.Errorf("unknown rate curation '%s'", c.RateCuration)
+	}
+
+	if c.RateLimit > 0 && len(curated) > c.RateLimit {
+		curated = curated[:c.RateLimit]
+	}
+
+	if c.RateLabels {
+		labelRates(curated, locale)
+	}
+
+	return curated, nil
+}
+
+// dedupeRates keeps only the fastest rate of those with the same cost
+func dedupeRates(rates []ShippingRate) []ShippingRate {
+	var deduped []ShippingRate
+
+	for _, rate := range rates {
+		duplicate := false
+
+		for i := range deduped {
+			if deduped[i].Cost != rate.Cost {
+				continue
+			}
+
+			duplicate = true
+			if isFaster(&rate, &deduped[i]) {
+				deduped[i] = rate
+			}
+			break
+		}
+
+		if !duplicate {
+			deduped = append(deduped, rate)
+		}
+	}
+
+	return deduped
+}
+
+// tierRates keeps the cheapest rate of each tier, prefixing the description
+// with the tier name
+func tierRates(rates []ShippingRate, locale *Locale) []ShippingRate {
+	var tiered []ShippingRate
+	seen := make(map[RateTier]bool)
+
+	for _, rate := range rates {
+		tier := RateTierFor(&rate)
+		if seen[tier] {
+			continue
+		}
+		seen[tier] = true
+
+		rate.Description = fmt.Sprintf("%s - %s", locale.Text(string(tier)), rate.Description)
+		tiered = append(tiered, rate)
+	}
+
+	// Keep the tiers in a consistent order of cost
+	sort.SliceStable(tiered, func(i, j int) bool {
+		return tiered[i].Cost < tiered[j].Cost
+	})
+
+	return tiered
+}
+
+// labelRates labels the cheapest and fastest rates in their descriptions
+func labelRates(rates []ShippingRate, locale *Locale) {
+	if len(rates) < 2 {
+		return
+	}
+
+	cheapest := 0
+	fastest := -1
+	for i := range rates {
+		if rates[i].Cost < rates[cheapest].Cost {
+			cheapest = i
+		}
+
+		if rates[i].deliveryDays > 0 && (fastest < 0 || isFaster(&rates[i], &rates[fastest])) {
+			fastest = i
+		}
+	}
+
+	rates[cheapest].Description = fmt.Sprintf("%s (%s)", rates[cheapest].Description, locale.Text(MsgCheapest))
+	if fastest >= 0 && fastest != cheapest {
+		rates[fastest].Description = fmt.Sprintf("%s (%s)", rates[fastest].Description, locale.Text(MsgFastest))
+	}
+}
+
+// isFaster reports whether rate a has a delivery estimate sooner than rate b,
+// treating rates without an estimate as the slowest
+func isFaster(a *ShippingRate, b *ShippingRate) bool {
+	if a.deliveryDays <= 0 {
+		return false
+	}
+
+	return b.deliveryDays <= 0 || a.deliveryDays < b.deliveryDays
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/debyltech/go-snipcart-webhook/config"
+)
+
+func TestCurateRates(t *testing.T) {
+	webhookConfig = &config.Config{Production: true}
+
+	locale := NewLocale("en")
+
+	rate := func(id string, cost float64, days int) ShippingRate {
+		return ShippingRate{Id: id, Cost: cost, Description: id, deliveryDays: days}
+	}
+
+	// Sorted by cost, with ties at 5 and 8
+	rates := []ShippingRate{
+		rate("a", 5, 7),
+		rate("b", 5, 3),
+		rate("c", 8, 2),
+		rate("d", 8, 0),
+		rate("e", 12, 1),
+		rate("f", 20, 6),
+	}
+
+	tests := []struct {
+		name     string
+		curation string
+		limit    int
+		dedupe   bool
+		want     []string
+	}{
+		{"all", RateCurationAll, 0, false, []string{"a", "b", "c", "d", "e", "f"}},
+		{"default", "", 0, false, []string{"a", "b", "c", "d", "e", "f"}},
+		{"cheapest keeps ties in order", RateCurationCheapest, 2, false, []string{"a", "b"}},
+		{"cheapest cuts a tie", RateCurationCheapest, 3, false, []string{"a", "b", "c"}},
+		{"cheapest deduped keeps fastest of ties", RateCurationCheapest, 3, true, []string{"b", "c", "e"}},
+		{"cheapest above rate count", RateCurationCheapest, 10, false, []string{"a", "b", "c", "d", "e", "f"}},
+		{"deduped", RateCurationAll, 0, true, []string{"b", "c", "e", "f"}},
+		{"tiers", RateCurationTiers, 0, false, []string{"a", "b", "c"}},
+		{"tiers deduped", RateCurationTiers, 0, true, []string{"b", "c", "f"}},
+		{"tiers limited", RateCurationTiers, 2, false, []string{"a", "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &config.Config{RateCuration: tt.curation, RateLimit: tt.limit, RateDedupe: tt.dedupe}
+
+			curated, err := CurateRates(c, append([]ShippingRate(nil), rates...), locale)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var ids []string
+			for _, rate := range curated {
+				ids = append(ids, rate.Id)
+			}
+
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("rates = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+
+	t.Run("unknown curation", func(t *testing.T) {
+		if _, err := CurateRates(&config.Config{RateCuration: "fastest"}, rates, locale); err == nil {
+			t.Error("no error for unknown curation")
+		}
+	})
+
+	t.Run("labels", func(t *testing.T) {
+		curated, err := CurateRates(&config.Config{RateLabels: true}, append([]ShippingRate(nil), rates...), locale)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.HasSuffix(curated[0].Description, "("+locale.Text(MsgCheapest)+")") {
+			t.Errorf("cheapest rate description = %q", curated[0].Description)
+		}
+
+		if !strings.HasSuffix(curated[4].Description, "("+locale.Text(MsgFastest)+")") {
+			t.Errorf("fastest rate description = %q", curated[4].Description)
+		}
+
+		if curated[1].Description != "b" {
+			t.Errorf("unlabeled rate description = %q", curated[1].Description)
+		}
+	})
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	"github.com/EasyPost/easypost-go/v4"
+)
+
+// DeliveryWindow is the range of calendar dates a rate is expected to deliver
+// on, which is a single date when the carrier guarantees it
+type DeliveryWindow struct {
+	Earliest   time.Time
+	Latest     time.Time
+	Guaranteed bool
+}
+
+// ShipDate returns the date an order placed at the given time ships, based on
+// the configured ship days, cutoff time, holidays, and handling time
+func ShipDate(orderedAt time.Time) time.Time {
+	return webhookConfig.ShipDate(orderedAt)
+}
+
+// EstimateDelivery returns the delivery window of the rate for a shipment
+// that ships on shipDate, or nil if the carrier gives no estimate. Shipments
+// are quoted with shipDate as their label date, so the carrier's delivery
+// date already accounts for the handling time
+func EstimateDelivery(rate *easypost.Rate, shipDate time.Time) *DeliveryWindow {
+	if rate.DeliveryDate != nil && !rate.DeliveryDate.IsZero() {
+		deliveryDate := *rate.DeliveryDate
+
+		window := DeliveryWindow{
+			Earliest:   deliveryDate,
+			Latest:     deliveryDate,
+			Guaranteed: rate.DeliveryDateGuaranteed,
+		}
+		if !window.Guaranteed {
+			window.Latest = webhookConfig.AddBusinessDays(deliveryDate, 1)
+		}
+
+		return &window
+	}
+
+	deliveryDays := RateDeliveryDays(rate)
+	if deliveryDays <= 0 {
+		return nil
+	}
+
+	window := DeliveryWindow{
+		Earliest:   webhookConfig.AddBusinessDays(shipDate, deliveryDays),
+		Guaranteed: rate.DeliveryDateGuaranteed,
+	}
+
+	// Estimates are not exact, so allow for an extra business day
+	window.Latest = window.Earliest
+	if !window.Guaranteed {
+		window.Latest = webhookConfig.AddBusinessDays(window.Earliest, 1)
+	}
+
+	return &window
+}
+
+// GuaranteedDays returns the calendar days from the given time until the
+// guaranteed delivery date, or 0 if delivery is not guaranteed
+func (w *DeliveryWindow) GuaranteedDays(from time.Time) int {
+	if w == nil || !w.Guaranteed {
+		return 0
+	}
+
+	return int(math.Round(truncateToDay(w.Latest).Sub(truncateToDay(from)).Hours() / 24))
+}
+
+func truncateToDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
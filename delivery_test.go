@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/EasyPost/easypost-go/v4"
+	"github.com/debyltech/go-snipcart-webhook/config"
+)
+
+func TestEstimateDelivery(t *testing.T) {
+	webhookConfig = &config.Config{
+		Production:   true,
+		HolidayDates: map[string]bool{"2026-11-26": true},
+	}
+
+	day := func(d int) time.Time {
+		return time.Date(2026, time.November, d, 0, 0, 0, 0, time.UTC)
+	}
+	date := func(d int) *time.Time {
+		t := day(d)
+		return &t
+	}
+
+	// Shipping on a Tuesday with Thanksgiving on Thursday
+	shipDate := day(24)
+
+	tests := []struct {
+		name           string
+		rate           easypost.Rate
+		wantEarliest   string
+		wantLatest     string
+		wantGuaranteed bool
+	}{
+		{"carrier date", easypost.Rate{DeliveryDate: date(27)}, "2026-11-27", "2026-11-30", false},
+		{"guaranteed carrier date", easypost.Rate{DeliveryDate: date(27), DeliveryDateGuaranteed: true}, "2026-11-27", "2026-11-27", true},
+		{"carrier date before days", easypost.Rate{DeliveryDate: date(25), DeliveryDays: 5}, "2026-11-25", "2026-11-27", false},
+		{"delivery days over holiday", easypost.Rate{DeliveryDays: 2}, "2026-11-27", "2026-11-30", false},
+		{"estimated days", easypost.Rate{EstDeliveryDays: 1}, "2026-11-25", "2026-11-27", false},
+		{"delivery days before estimate", easypost.Rate{DeliveryDays: 1, EstDeliveryDays: 4}, "2026-11-25", "2026-11-27", false},
+		{"zero carrier date", easypost.Rate{DeliveryDate: &time.Time{}, DeliveryDays: 3}, "2026-11-30", "2026-12-01", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			window := EstimateDelivery(&tt.rate, shipDate)
+			if window == nil {
+				t.Fatal("no delivery window")
+			}
+
+			earliest := window.Earliest.Format(config.DateLayout)
+			latest := window.Latest.Format(config.DateLayout)
+			if earliest != tt.wantEarliest || latest != tt.wantLatest || window.Guaranteed != tt.wantGuaranteed {
+				t.Errorf("window = %s - %s guaranteed %t, want %s - %s guaranteed %t", earliest, latest, window.Guaranteed, tt.wantEarliest, tt.wantLatest, tt.wantGuaranteed)
+			}
+		})
+	}
+
+	t.Run("no estimate", func(t *testing.T) {
+		if window := EstimateDelivery(&easypost.Rate{}, shipDate); window != nil {
+			t.Errorf("window = %+v, want nil", window)
+		}
+	})
+
+	t.Run("guaranteed days", func(t *testing.T) {
+		guaranteed := &DeliveryWindow{Earliest: day(27), Latest: day(27), Guaranteed: true}
+		ratedAt := time.Date(2026, time.November, 23, 15, 30, 0, 0, time.UTC)
+
+		if got := guaranteed.GuaranteedDays(ratedAt); got != 4 {
+			t.Errorf("GuaranteedDays = %d, want 4", got)
+		}
+
+		estimated := &DeliveryWindow{Earliest: day(27), Latest: day(30)}
+		if got := estimated.GuaranteedDays(ratedAt); got != 0 {
+			t.Errorf("GuaranteedDays = %d for an estimate, want 0", got)
+		}
+	})
+}
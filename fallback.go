@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// FallbackRateIdPrefix starts the rate IDs given to Snipcart for fallback
+// rates, which have no EasyPost rate to buy
+const FallbackRateIdPrefix string = "fallback_"
+
+func IsFallbackRateId(rateId string) bool {
+	return strings.HasPrefix(rateId, FallbackRateIdPrefix)
+}
+
+// FallbackShippingRates returns the configured fallback rates for the zone of
+// the order's destination, or nil when the zone has none. The shipping
+// discount and rules apply to fallback rates like live ones, where rules
+// limited to carriers or services never match as fallback rates have neither
+func FallbackShippingRates(order *WebhookOrder) *ShippingRatesResponse {
+	rateContext := NewRateContext(order)
+
+	fallbackRates := webhookConfig.FallbackRatesFor(rateContext.Zone)
+	if len(fallbackRates) == 0 {
+		return nil
+	}
+
+	var ratesResponse ShippingRatesResponse
+	for i, rate := range fallbackRates {
+		ratesResponse.Rates = append(ratesResponse.Rates, ShippingRate{
+			Id:          fmt.Sprintf("%s%s_%d", FallbackRateIdPrefix, rateContext.Zone, i),
+			Cost:        ApplyShippingRules(webhookConfig.ShippingRules, &rateContext, DiscountedCost(rate.Cost, webhookConfig.ShippingDiscount)),
+			Description: rate.Description,
+		})
+	}
+
+	return &ratesResponse
+}
+
+// fallbackOrError returns the fallback rates for the order when live rating
+// failed, logging the reason as a warning, or the error when there are no
+// fallback rates for the order's zone
+func fallbackOrError(order *WebhookOrder, reason error) (any, error) {
+	fallbackRates := FallbackShippingRates(order)
+	if fallbackRates == nil {
+		return http.StatusInternalServerError, reason
+	}
+
+	logJsonWithStatus(JsonLogStatusWarning, "shippingrates.fetch", fmt.Sprintf("using fallback rates for %s: %s", order.Token, reason.Error()))
+	return fallbackRates, nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/debyltech/go-snipcart-webhook/config"
+	"github.com/debyltech/go-snipcart/snipcart"
+)
+
+func TestFallbackShippingRates(t *testing.T) {
+	webhookConfig = &config.Config{
+		Production: true,
+		Zones:      config.DefaultZones,
+		FallbackRates: map[string][]config.FallbackRate{
+			config.ZoneDomestic: {
+				{Description: "Standard", Cost: 8},
+				{Description: "Express", Cost: 25},
+			},
+		},
+		ShippingRules: []config.ShippingRule{
+			{Type: config.ShippingRuleFreeShipping, MinSubtotal: 100},
+			{Type: config.ShippingRuleFlatRate, Coupons: []string{"FLAT5"}, Cost: 5},
+			{Type: config.ShippingRuleFreeShipping, Carriers: []string{"USPS"}},
+		},
+	}
+
+	order := func(subtotal float64, coupon string) *WebhookOrder {
+		o := &WebhookOrder{
+			Order:      snipcart.Order{Token: "token", ShippingAddress: snipcart.Address{Country: "US"}},
+			ItemsTotal: subtotal,
+		}
+		if coupon != "" {
+			o.Discounts = []WebhookDiscount{{Code: coupon}}
+		}
+
+		return o
+	}
+
+	tests := []struct {
+		name  string
+		order *WebhookOrder
+		want  []float64
+	}{
+		{"configured costs", order(50, ""), []float64{8, 25}},
+		{"free shipping above subtotal", order(100, ""), []float64{0, 0}},
+		{"flat rate with coupon", order(50, "FLAT5"), []float64{5, 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rates := FallbackShippingRates(tt.order)
+			if rates == nil {
+				t.Fatal("no fallback rates")
+			}
+
+			var costs []float64
+			for _, rate := range rates.Rates {
+				if !IsFallbackRateId(rate.Id) {
+					t.Errorf("rate ID %s is not a fallback rate ID", rate.Id)
+				}
+				costs = append(costs, rate.Cost)
+			}
+
+			if !reflect.DeepEqual(costs, tt.want) {
+				t.Errorf("costs = %v, want %v", costs, tt.want)
+			}
+		})
+	}
+
+	if rates := FallbackShippingRates(&WebhookOrder{Order: snipcart.Order{ShippingAddress: snipcart.Address{Country: "FR"}}}); rates != nil {
+		t.Errorf("rates = %+v for a zone without fallback rates, want nil", rates)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"time"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/message/catalog"
+)
+
+// Message keys are the English text, which is used as is when the customer's
+// language has no translation
+const (
+	MsgEstimatedArrival  string = "Estimated arrival %s"
+	MsgGuaranteedArrival string = "Guaranteed arrival %s"
+	MsgDeliveryDate      string = "%[1]s, %[2]s %[3]d"
+	MsgCheapest          string = "Cheapest"
+	MsgFastest           string = "Fastest"
+
+	MsgInvalidAddressName              string = "Shipping Address name must be at least two words (ex. 'Jon D', 'Jon Doe')"
+	MsgInvalidAddressFirstName         string = "Shipping Address first name must be longer than two characters (ex: 'Jon')"
+	MsgInvalidAddressStreet            string = "Shipping Address street could not be found, please check it"
+	MsgInvalidAddressCity              string = "Shipping Address city does not match the postal code"
+	MsgInvalidAddressProvince          string = "Shipping Address state or province does not match the postal code"
+	MsgInvalidAddressPostalCode        string = "Shipping Address postal code could not be found, please check it"
+	MsgUndeliverableAddress            string = "Shipping Address could not be verified as deliverable, please check it"
+	MsgAddressSuggestion               string = "Shipping Address was corrected to '%s', please update it or submit it again to confirm"
+	MsgInvalidAddressPOBox             string = "Shipping Address cannot be a PO box"
+	MsgMissingAddressPhone             string = "Shipping Address phone number is required for international shipments"
+	MsgInvalidAddressPostalCodeFormat  string = "Shipping Address postal code is not in a valid format"
+	MsgInvalidAddressLineLength        string = "Shipping Address lines must be at most %d characters"
+	MsgInvalidAddressCharacters        string = "Shipping Address must be written in Latin characters"
+	MsgInvalidAddressPostalCodeExample string = "Shipping Address postal code is not valid for the country (ex. '%s')"
+	MsgMissingAddressStreet            string = "Shipping Address street is required"
+	MsgMissingAddressCity              string = "Shipping Address city is required"
+	MsgMissingAddressProvince          string = "Shipping Address state or province is required for the country"
+	MsgMissingAddressPostalCode        string = "Shipping Address postal code is required for the country"
+)
+
+var (
+	// SupportedLanguages are the languages with translations, where the first is
+	// the fallback for any other language
+	SupportedLanguages []language.Tag = []language.Tag{
+		language.English,
+		language.French,
+		language.German,
+		language.Spanish,
+	}
+
+	languageMatcher language.Matcher = language.NewMatcher(SupportedLanguages)
+
+	weekdayNames []string = []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+	monthNames   []string = []string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"}
+
+	translations map[language.Tag]map[string]string = map[language.Tag]map[string]string{
+		language.French: {
+			MsgEstimatedArrival:                "Arrivée estimée %s",
+			MsgGuaranteedArrival:               "Arrivée garantie %s",
+			MsgDeliveryDate:                    "%[1]s %[3]d %[2]s",
+			MsgCheapest:                        "Le moins cher",
+			MsgFastest:                         "Le plus rapide",
+			MsgInvalidAddressName:              "Le nom de l'adresse de livraison doit comporter au moins deux mots (ex. 'Jean D', 'Jean Dupont')",
+			MsgInvalidAddressFirstName:         "Le prénom de l'adresse de livraison doit comporter plus de deux caractères (ex. 'Jean')",
+			MsgInvalidAddressStreet:            "La rue de l'adresse de livraison est introuvable, veuillez la vérifier",
+			MsgInvalidAddressCity:              "La ville de l'adresse de livraison ne correspond pas au code postal",
+			MsgInvalidAddressProvince:          "L'état ou la province de l'adresse de livraison ne correspond pas au code postal",
+			MsgInvalidAddressPostalCode:        "Le code postal de l'adresse de livraison est introuvable, veuillez le vérifier",
+			MsgUndeliverableAddress:            "L'adresse de livraison n'a pas pu être vérifiée comme livrable, veuillez la vérifier",
+			MsgAddressSuggestion:               "L'adresse de livraison a été corrigée en '%s', veuillez la mettre à jour ou la soumettre à nouveau pour confirmer",
+			MsgInvalidAddressPOBox:             "L'adresse de livraison ne peut pas être une boîte postale",
+			MsgMissingAddressPhone:             "Le numéro de téléphone de l'adresse de livraison est obligatoire pour les envois internationaux",
+			MsgInvalidAddressPostalCodeFormat:  "Le format du code postal de l'adresse de livraison n'est pas valide",
+			MsgInvalidAddressLineLength:        "Les lignes de l'adresse de livraison doivent comporter au plus %d caractères",
+			MsgInvalidAddressCharacters:        "L'adresse de livraison doit être écrite en caractères latins",
+			MsgInvalidAddressPostalCodeExample: "Le code postal de l'adresse de livraison n'est pas valide pour ce pays (ex. '%s')",
+			MsgMissingAddressStreet:            "La rue de l'adresse de livraison est obligatoire",
+			MsgMissingAddressCity:              "La ville de l'adresse de livraison est obligatoire",
+			MsgMissingAddressProvince:          "L'état ou la province de l'adresse de livraison est obligatoire pour ce pays",
+			MsgMissingAddressPostalCode:        "Le code postal de l'adresse de livraison est obligatoire pour ce pays",
+			string(RateTierExpress):            "Express",
+			string(RateTierStandard):           "Standard",
+			string(RateTierEconomy):            "Économique",
+			"Sun":                              "dim.",
+			"Mon":                              "lun.",
+			"Tue":                              "mar.",
+			"Wed":                              "mer.",
+			"Thu":                              "jeu.",
+			"Fri":                              "ven.",
+			"Sat":                              "sam.",
+			"Jan":                              "janv.",
+			"Feb":                              "févr.",
+			"Mar":                              "mars",
+			"Apr":                              "avr.",
+			"May":                              "mai",
+			"Jun":                              "juin",
+			"Jul":                              "juil.",
+			"Aug":                              "août",
+			"Sep":                              "sept.",
+			"Oct":                              "oct.",
+			"Nov":                              "nov.",
+			"Dec":                              "déc.",
+		},
+		language.German: {
+			MsgEstimatedArrival:                "Voraussichtliche Ankunft %s",
+			MsgGuaranteedArrival:               "Garantierte Ankunft %s",
+			MsgDeliveryDate:                    "%[1]s, %[3]d. %[2]s",
+			MsgCheapest:                        "Günstigste",
+			MsgFastest:                         "Schnellste",
+			MsgInvalidAddressName:              "Der Name der Lieferadresse muss aus mindestens zwei Wörtern bestehen (z. B. 'Max M', 'Max Mustermann')",
+			MsgInvalidAddressFirstName:         "Der Vorname der Lieferadresse muss länger als zwei Zeichen sein (z. B. 'Max')",
+			MsgInvalidAddressStreet:            "Die Straße der Lieferadresse wurde nicht gefunden, bitte überprüfen",
+			MsgInvalidAddressCity:              "Der Ort der Lieferadresse passt nicht zur Postleitzahl",
+			MsgInvalidAddressProvince:          "Das Bundesland der Lieferadresse passt nicht zur Postleitzahl",
+			MsgInvalidAddressPostalCode:        "Die Postleitzahl der Lieferadresse wurde nicht gefunden, bitte überprüfen",
+			MsgUndeliverableAddress:            "Die Lieferadresse konnte nicht als zustellbar bestätigt werden, bitte überprüfen",
+			MsgAddressSuggestion:               "Die Lieferadresse wurde zu '%s' korrigiert, bitte aktualisieren oder zur Bestätigung erneut absenden",
+			MsgInvalidAddressPOBox:             "Die Lieferadresse darf kein Postfach sein",
+			MsgMissingAddressPhone:             "Für internationale Sendungen ist eine Telefonnummer in der Lieferadresse erforderlich",
+			MsgInvalidAddressPostalCodeFormat:  "Die Postleitzahl der Lieferadresse hat kein gültiges Format",
+			MsgInvalidAddressLineLength:        "Die Zeilen der Lieferadresse dürfen höchstens %d Zeichen lang sein",
+			MsgInvalidAddressCharacters:        "Die Lieferadresse muss in lateinischen Buchstaben geschrieben sein",
+			MsgInvalidAddressPostalCodeExample: "Die Postleitzahl der Lieferadresse ist für dieses Land ungültig (z. B. '%s')",
+			MsgMissingAddressStreet:            "Die Straße der Lieferadresse ist erforderlich",
+			MsgMissingAddressCity:              "Der Ort der Lieferadresse ist erforderlich",
+			MsgMissingAddressProvince:          "Das Bundesland der Lieferadresse ist für dieses Land erforderlich",
+			MsgMissingAddressPostalCode:        "Die Postleitzahl der Lieferadresse ist für dieses Land erforderlich",
+			string(RateTierExpress):            "Express",
+			string(RateTierStandard):           "Standard",
+			string(RateTierEconomy):            "Sparversand",
+			"Sun":                              "So.",
+			"Mon":                              "Mo.",
+			"Tue":                              "Di.",
+			"Wed":                              "Mi.",
+			"Thu":                              "Do.",
+			"Fri":                              "Fr.",
+			"Sat":                              "Sa.",
+			"Jan":                              "Jan.",
+			"Feb":                              "Feb.",
+			"Mar":                              "März",
+			"Apr":                              "Apr.",
+			"May":                              "Mai",
+			"Jun":                              "Juni",
+			"Jul":                              "Juli",
+			"Aug":                              "Aug.",
+			"Sep":                              "Sept.",
+			"Oct":                              "Okt.",
+			"Nov":                              "Nov.",
+			"Dec":                              "Dez.",
+		},
+		language.Spanish: {
+			MsgEstimatedArrival:                "Llegada estimada %s",
+			MsgGuaranteedArrival:               "Llegada garantizada %s",
+			MsgDeliveryDate:                    "%[1]s, %[3]d %[2]s",
+			MsgCheapest:                        "Más barato",
+			MsgFastest:                         "Más rápido",
+			MsgInvalidAddressName:              "El nombre de la dirección de envío debe tener al menos dos palabras (ej. 'Juan P', 'Juan Pérez')",
+			MsgInvalidAddressFirstName:         "El nombre de pila de la dirección de envío debe tener más de dos caracteres (ej. 'Juan')",
+			MsgInvalidAddressStreet:            "No se encontró la calle de la dirección de envío, por favor revísela",
+			MsgInvalidAddressCity:              "La ciudad de la dirección de envío no coincide con el código postal",
+			MsgInvalidAddressProvince:          "El estado o provincia de la dirección de envío no coincide con el código postal",
+			MsgInvalidAddressPostalCode:        "No se encontró el código postal de la dirección de envío, por favor revíselo",
+			MsgUndeliverableAddress:            "No se pudo verificar que la dirección de envío sea entregable, por favor revísela",
+			MsgAddressSuggestion:               "La dirección de envío se corrigió a '%s', por favor actualícela o envíela de nuevo para confirmar",
+			MsgInvalidAddressPOBox:             "La dirección de envío no puede ser un apartado postal",
+			MsgMissingAddressPhone:             "El teléfono de la dirección de envío es obligatorio para envíos internacionales",
+			MsgInvalidAddressPostalCodeFormat:  "El código postal de la dirección de envío no tiene un formato válido",
+			MsgInvalidAddressLineLength:        "Las líneas de la dirección de envío deben tener como máximo %d caracteres",
+			MsgInvalidAddressCharacters:        "La dirección de envío debe estar escrita en caracteres latinos",
+			MsgInvalidAddressPostalCodeExample: "El código postal de la dirección de envío no es válido para el país (ej. '%s')",
+			MsgMissingAddressStreet:            "La calle de la dirección de envío es obligatoria",
+			MsgMissingAddressCity:              "La ciudad de la dirección de envío es obligatoria",
+			MsgMissingAddressProvince:          "El estado o provincia de la dirección de envío es obligatorio para el país",
+			MsgMissingAddressPostalCode:        "El código postal de la dirección de envío es obligatorio para el país",
+			string(RateTierExpress):            "Exprés",
+			string(RateTierStandard):           "Estándar",
+			string(RateTierEconomy):            "Económico",
+			"Sun":                              "dom",
+			"Mon":                              "lun",
+			"Tue":                              "mar",
+			"Wed":                              "mié",
+			"Thu":                              "jue",
+			"Fri":                              "vie",
+			"Sat":                              "sáb",
+			"Jan":                              "ene",
+			"Feb":                              "feb",
+			"Mar":                              "mar",
+			"Apr":                              "abr",
+			"May":                              "may",
+			"Jun":                              "jun",
+			"Jul":                              "jul",
+			"Aug":                              "ago",
+			"Sep":                              "sept",
+			"Oct":                              "oct",
+			"Nov":                              "nov",
+			"Dec":                              "dic",
+		},
+	}
+
+	messageCatalog catalog.Catalog = newMessageCatalog()
+)
+
+func newMessageCatalog() catalog.Catalog {
+	builder := catalog.NewBuilder(catalog.Fallback(language.English))
+
+	for tag, messages := range translations {
+		for key, msg := range messages {
+			if err := builder.SetString(tag, key, msg); err != nil {
+				panic(err)
+			}
+		}
+	}
+
+	return builder
+}
+
+// Locale renders customer facing text in the customer's language, falling
+// back to English for languages without translations
+type Locale struct {
+	Tag     language.Tag
+	printer *message.Printer
+}
+
+// NewLocale creates the Locale for the language Snipcart provides for the
+// customer (i.e. "fr" or "fr-CA")
+func NewLocale(lang string) *Locale {
+	_, index, _ := languageMatcher.Match(language.Make(lang))
+	tag := SupportedLanguages[index]
+
+	return &Locale{
+		Tag:     tag,
+		printer: message.NewPrinter(tag, message.Catalog(messageCatalog)),
+	}
+}
+
+// Text returns the translation of the message key formatted with the args
+func (l *Locale) Text(key string, a ...any) string {
+	return l.printer.Sprintf(key, a...)
+}
+
+// FormatDate formats the date with the abbreviated weekday and month (i.e.
+// "Mon, Jan 2" in English)
+func (l *Locale) FormatDate(t time.Time) string {
+	weekday := l.Text(weekdayNames[t.Weekday()])
+	month := l.Text(monthNames[t.Month()-1])
+
+	return l.Text(MsgDeliveryDate, weekday, month, t.Day())
+}
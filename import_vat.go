@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/EasyPost/easypost-go/v4"
+	"github.com/debyltech/go-snipcart-webhook/config"
+	"github.com/debyltech/go-snipcart/snipcart"
+)
+
+// ImportVATScheme is a destination's scheme for collecting VAT on low value
+// imports at checkout, declared to the carrier with our registration number
+// so the customer is not charged import VAT on delivery
+type ImportVATScheme struct {
+	Name           string
+	Country        string
+	IssuingCountry string
+	TaxIdType      string
+	Currency       string
+	Threshold      float64
+	// PerItem applies the threshold to the value of each item rather than the
+	// value of the whole consignment
+	PerItem bool
+}
+
+var (
+	// The EU Import One-Stop Shop applies to consignments up to €150, and is
+	// used for every EU country
+	IOSSScheme ImportVATScheme = ImportVATScheme{
+		Name:           "IOSS",
+		IssuingCountry: "ES",
+		TaxIdType:      "IOSS",
+		Currency:       "EUR",
+		Threshold:      150,
+	}
+
+	ImportVATSchemes []ImportVATScheme = []ImportVATScheme{
+		{
+			// UK VAT is collected by the seller for consignments up to £135
+			Name:           "UK VAT",
+			Country:        "gb",
+			IssuingCountry: "GB",
+			TaxIdType:      "VAT",
+			Currency:       "GBP",
+			Threshold:      135,
+		},
+		{
+			// Norway's VAT On E-Commerce applies to items under NOK 3000
+			Name:           "VOEC",
+			Country:        "no",
+			IssuingCountry: "NO",
+			TaxIdType:      "VOEC",
+			Currency:       "NOK",
+			Threshold:      3000,
+			PerItem:        true,
+		},
+	}
+
+	// ImportCountryVAT is the fallback standard VAT rate for import VAT scheme
+	// countries without a rate configured in GSW_VAT_RATES_JSON or
+	// GSW_VAT_RATES_FILE
+	ImportCountryVAT map[string]float64 = map[string]float64{
+		"gb": 0.20, // United Kingdom
+		"no": 0.25, // Norway
+	}
+
+	// Countries where import VAT is always paid by the customer on delivery
+	DutiesUnpaidCountries []string = []string{
+		"ch", // Switzerland
+	}
+
+	// Incoterms
+	INCOTERM_DDU string = "DDU"
+)
+
+// ImportVATSchemeFor returns the import VAT scheme of the country, or nil if
+// the country has none
+func ImportVATSchemeFor(country string) *ImportVATScheme {
+	if IsEUCountry(country) {
+		return &IOSSScheme
+	}
+
+	for i, scheme := range ImportVATSchemes {
+		if strings.EqualFold(scheme.Country, country) {
+			return &ImportVATSchemes[i]
+		}
+	}
+
+	return nil
+}
+
+// TaxId returns our registration number for the scheme from the config
+func (s *ImportVATScheme) TaxId(c *config.Config) string {
+	switch s.TaxIdType {
+	case "VAT":
+		return c.VAT
+	case "VOEC":
+		return c.VOEC
+	case "IOSS":
+		return c.IOSS
+	}
+
+	return ""
+}
+
+// Applies reports whether VAT is collected under the scheme for goods of the
+// given total value and highest item value in the order currency. The scheme
+// never applies when we have no registration number configured for it
+func (s *ImportVATScheme) Applies(c *config.Config, total float64, maxItem float64, currency string) (bool, error) {
+	if s.TaxId(c) == "" {
+		return false, nil
+	}
+
+	value := total
+	if s.PerItem {
+		value = maxItem
+	}
+
+	converted, err := c.ConvertCurrency(value, currency, s.Currency)
+	if err != nil {
+		return false, fmt.Errorf("error with converting value for %s: %s", s.Name, err.Error())
+	}
+
+	DebugPrintf("%s value %f %s is %f %s, threshold %f", s.Name, value, currency, converted, s.Currency, s.Threshold)
+
+	return converted <= s.Threshold, nil
+}
+
+// DiscountFactor returns the share of the items' price left after the
+// order's discounts, where itemsTotal is Snipcart's items total with discounts
+// applied. Spreading discounts by it keeps VAT and import thresholds in line
+// with what the customer pays
+func DiscountFactor(items []snipcart.Item, itemsTotal float64) float64 {
+	itemsSum := 0.0
+	for _, item := range items {
+		itemsSum += item.TotalPrice
+	}
+
+	if itemsSum <= 0 {
+		return 1
+	}
+
+	return itemsTotal / itemsSum
+}
+
+// ConsignmentValue returns the discounted value of the shippable items and the
+// highest discounted unit price among them, as used for import thresholds
+func ConsignmentValue(items []snipcart.Item, itemsTotal float64) (float64, float64) {
+	discountFactor := DiscountFactor(items, itemsTotal)
+
+	var total, maxItem float64
+	for _, item := range items {
+		if !item.Shippable {
+			continue
+		}
+
+		total += item.TotalPrice * discountFactor
+		if price := item.Price * discountFactor; price > maxItem {
+			maxItem = price
+		}
+	}
+
+	return total, maxItem
+}
+
+// ImportVATApplies decides whether VAT is collected under the scheme for the
+// order. Both the checkout taxes and the shipment's tax identifier and
+// incoterm use it so they always agree. Conversion errors are logged and the
+// scheme treated as not applying, so no VAT is collected and the shipment is
+// sent duties unpaid rather than charging import VAT twice
+func ImportVATApplies(scheme *ImportVATScheme, items []snipcart.Item, itemsTotal float64, currency string, event string) bool {
+	total, maxItem := ConsignmentValue(items, itemsTotal)
+
+	// The built in exchange rates go stale, so deciding a threshold with them
+	// is worth knowing about
+	if webhookConfig.ExchangeRatesJson == "" && !strings.EqualFold(currency, scheme.Currency) {
+		logJsonWithStatus(JsonLogStatusWarning, event, fmt.Sprintf("converting %s to %s for %s with the built in exchange rates, set GSW_EXCHANGE_RATES_JSON", strings.ToUpper(currency), scheme.Currency, scheme.Name))
+	}
+
+	applies, err := scheme.Applies(webhookConfig, total, maxItem, currency)
+	if err != nil {
+		logJsonWithStatus(JsonLogStatusError, event, err.Error())
+		return false
+	}
+
+	return applies
+}
+
+// WarnMissingImportVATIds logs a warning for every import VAT scheme without a
+// registration number configured, as VAT is then not collected for the
+// scheme's countries and their shipments are sent duties unpaid
+func WarnMissingImportVATIds(c *config.Config) {
+	schemes := append([]ImportVATScheme{IOSSScheme}, ImportVATSchemes...)
+	for i := range schemes {
+		if schemes[i].TaxId(c) == "" {
+			logJsonWithStatus(JsonLogStatusWarning, "config", fmt.Sprintf("no %s number configured, %s VAT is not collected and shipments are sent duties unpaid", schemes[i].TaxIdType, schemes[i].Name))
+		}
+	}
+}
+
+// IsDutiesUnpaidCountry reports whether the customer always pays import VAT
+// and duties on delivery in the country
+func IsDutiesUnpaidCountry(country string) bool {
+	for _, c := range DutiesUnpaidCountries {
+		if strings.EqualFold(c, country) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetDutiesUnpaid marks the shipment as Delivered Duty Unpaid, so the carrier
+// collects import VAT and duties from the customer
+func SetDutiesUnpaid(shipment *easypost.Shipment) {
+	if shipment.Options == nil {
+		shipment.Options = &easypost.ShipmentOptions{}
+	}
+
+	shipment.Options.Incoterm = INCOTERM_DDU
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/debyltech/go-snipcart-webhook/config"
+	"github.com/debyltech/go-snipcart/snipcart"
+)
+
+func TestConsignmentValue(t *testing.T) {
+	tests := []struct {
+		name        string
+		items       []snipcart.Item
+		itemsTotal  float64
+		wantTotal   float64
+		wantMaxItem float64
+	}{
+		{
+			name:        "no discount",
+			items:       []snipcart.Item{{Price: 50, TotalPrice: 100, Quantity: 2, Shippable: true}, {Price: 30, TotalPrice: 30, Quantity: 1, Shippable: true}},
+			itemsTotal:  130,
+			wantTotal:   130,
+			wantMaxItem: 50,
+		},
+		{
+			name:        "discount spread across items",
+			items:       []snipcart.Item{{Price: 100, TotalPrice: 100, Quantity: 1, Shippable: true}, {Price: 100, TotalPrice: 100, Quantity: 1, Shippable: true}},
+			itemsTotal:  150,
+			wantTotal:   150,
+			wantMaxItem: 75,
+		},
+		{
+			name:        "digital items not in consignment",
+			items:       []snipcart.Item{{Price: 100, TotalPrice: 100, Quantity: 1, Shippable: true}, {Price: 200, TotalPrice: 200, Quantity: 1}},
+			itemsTotal:  300,
+			wantTotal:   100,
+			wantMaxItem: 100,
+		},
+		{
+			name:        "free items",
+			items:       []snipcart.Item{{Price: 0, TotalPrice: 0, Quantity: 1, Shippable: true}},
+			itemsTotal:  20,
+			wantTotal:   0,
+			wantMaxItem: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			total, maxItem := ConsignmentValue(tt.items, tt.itemsTotal)
+
+			if math.Abs(total-tt.wantTotal) > 0.0001 || math.Abs(maxItem-tt.wantMaxItem) > 0.0001 {
+				t.Errorf("ConsignmentValue = %f, %f, want %f, %f", total, maxItem, tt.wantTotal, tt.wantMaxItem)
+			}
+		})
+	}
+}
+
+func TestImportVATApplies(t *testing.T) {
+	item := func(price float64, quantity int) snipcart.Item {
+		return snipcart.Item{Price: price, TotalPrice: price * float64(quantity), Quantity: quantity, Shippable: true}
+	}
+
+	ioss := ImportVATSchemeFor("FR")
+	ukVAT := ImportVATSchemeFor("GB")
+	voec := ImportVATSchemeFor("NO")
+
+	tests := []struct {
+		name       string
+		scheme     *ImportVATScheme
+		items      []snipcart.Item
+		itemsTotal float64
+		currency   string
+		noIOSS     bool
+		want       bool
+	}{
+		{"IOSS at threshold", ioss, []snipcart.Item{item(150, 1)}, 150, "eur", false, true},
+		{"IOSS above threshold", ioss, []snipcart.Item{item(150.01, 1)}, 150.01, "eur", false, false},
+		{"IOSS converted at threshold", ioss, []snipcart.Item{item(100, 3)}, 300, "usd", false, true},
+		{"IOSS converted above threshold", ioss, []snipcart.Item{item(100.01, 3)}, 300.03, "usd", false, false},
+		{"IOSS discounted to threshold", ioss, []snipcart.Item{item(100, 2)}, 150, "eur", false, true},
+		{"IOSS discounted above threshold", ioss, []snipcart.Item{item(100, 2)}, 150.01, "eur", false, false},
+		{"IOSS without digital items", ioss, []snipcart.Item{item(150, 1), {Price: 50, TotalPrice: 50, Quantity: 1}}, 200, "eur", false, true},
+		{"IOSS not configured", ioss, []snipcart.Item{item(10, 1)}, 10, "eur", true, false},
+		{"IOSS unknown currency", ioss, []snipcart.Item{item(10, 1)}, 10, "jpy", false, false},
+		{"UK VAT at threshold", ukVAT, []snipcart.Item{item(45, 3)}, 135, "gbp", false, true},
+		{"UK VAT above threshold", ukVAT, []snipcart.Item{item(45, 3)}, 135.01, "gbp", false, false},
+		{"VOEC per item at threshold", voec, []snipcart.Item{item(3000, 2)}, 6000, "nok", false, true},
+		{"VOEC per item above threshold", voec, []snipcart.Item{item(3000.01, 1)}, 3000.01, "nok", false, false},
+		{"VOEC discounted to threshold", voec, []snipcart.Item{item(3200, 1)}, 3000, "nok", false, true},
+		{"VOEC converted above threshold", voec, []snipcart.Item{item(300.01, 1)}, 300.01, "usd", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			webhookConfig = &config.Config{
+				Production:        true,
+				IOSS:              "IM1234567890",
+				VAT:               "GB123456789",
+				VOEC:              "VOEC1234567",
+				ExchangeRatesJson: `{"USD":1,"EUR":0.5,"GBP":0.5,"NOK":10}`,
+				ExchangeRates:     map[string]float64{"USD": 1, "EUR": 0.5, "GBP": 0.5, "NOK": 10},
+			}
+			if tt.noIOSS {
+				webhookConfig.IOSS = ""
+			}
+
+			if got := ImportVATApplies(tt.scheme, tt.items, tt.itemsTotal, tt.currency, "test"); got != tt.want {
+				t.Errorf("ImportVATApplies = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
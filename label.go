@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/EasyPost/easypost-go/v4"
+	"github.com/debyltech/go-snipcart/snipcart"
+)
+
+// PurchasedLabel holds the details of a purchased EasyPost label for one
+// parcel of an order
+type PurchasedLabel struct {
+	ShipmentId   string `json:"shipmentId"`
+	TrackingCode string `json:"trackingCode"`
+	TrackingUrl  string `json:"trackingUrl,omitempty"`
+	LabelUrl     string `json:"labelUrl"`
+}
+
+// LabelPurchase holds the purchased EasyPost labels for an order, with one
+// label per parcel
+type LabelPurchase struct {
+	OrderToken string           `json:"orderToken"`
+	Carrier    string           `json:"carrier"`
+	Service    string           `json:"service"`
+	Labels     []PurchasedLabel `json:"labels"`
+}
+
+// NewPurchasedLabel creates a PurchasedLabel from a shipment that has been
+// bought
+func NewPurchasedLabel(shipment *easypost.Shipment) PurchasedLabel {
+	label := PurchasedLabel{
+		ShipmentId:   shipment.ID,
+		TrackingCode: shipment.TrackingCode,
+	}
+
+	if shipment.PostageLabel != nil {
+		label.LabelUrl = shipment.PostageLabel.LabelURL
+	}
+
+	if shipment.Tracker != nil {
+		label.TrackingUrl = shipment.Tracker.PublicURL
+	}
+
+	return label
+}
+
+// NewLabelPurchase creates a LabelPurchase from shipments that have been bought
+func NewLabelPurchase(orderToken string, shipments []*easypost.Shipment) *LabelPurchase {
+	purchase := LabelPurchase{
+		OrderToken: orderToken,
+	}
+
+	for _, shipment := range shipments {
+		if purchase.Carrier == "" && shipment.SelectedRate != nil {
+			purchase.Carrier = shipment.SelectedRate.Carrier
+			purchase.Service = shipment.SelectedRate.Service
+		}
+
+		purchase.Labels = append(purchase.Labels, NewPurchasedLabel(shipment))
+	}
+
+	return &purchase
+}
+
+// TrackingCodes returns the tracking codes of all labels
+func (p *LabelPurchase) TrackingCodes() []string {
+	var codes []string
+	for _, label := range p.Labels {
+		codes = append(codes, label.TrackingCode)
+	}
+
+	return codes
+}
+
+// TrackingUrl returns the tracking URL of the first label
+func (p *LabelPurchase) TrackingUrl() string {
+	if len(p.Labels) == 0 {
+		return ""
+	}
+
+	return p.Labels[0].TrackingUrl
+}
+
+func isShipmentBought(shipment *easypost.Shipment) bool {
+	return shipment.PostageLabel != nil && shipment.PostageLabel.LabelURL != ""
+}
+
+// BuyOrderLabel buys the EasyPost rate the customer selected at checkout,
+// which Snipcart provides as the order's shipping rate ID. If the shipment
+// already has a label, the existing label is returned instead of buying a new
+// one as Snipcart may deliver the same webhook more than once
+func BuyOrderLabel(easypostClient *easypost.Client, order *snipcart.Order) (*LabelPurchase, error) {
+	if order.ShippingRateId == "" {
+		return nil, fmt.Errorf("order %s has no shipping rate selected", order.Token)
+	}
+
+	// Orders packed into several parcels were quoted as an EasyPost order
+	if orderId, carrier, service, ok := ParseOrderRateId(order.ShippingRateId); ok {
+		return buyEasypostOrder(easypostClient, order.Token, orderId, carrier, service)
+	}
+
+	rate, err := easypostClient.GetRate(order.ShippingRateId)
+	if err != nil {
+		return nil, fmt.Errorf("error with fetching selected rate: %s", err.Error())
+	}
+
+	shipment, err := easypostClient.GetShipment(rate.ShipmentID)
+	if err != nil {
+		return nil, fmt.Errorf("error with fetching shipment for selected rate: %s", err.Error())
+	}
+
+	if isShipmentBought(shipment) {
+		logJsonWithStatus(JsonLogStatusWarning, "order.completed", fmt.Sprintf("label already purchased for %s, skipping", order.Token))
+		return NewLabelPurchase(order.Token, []*easypost.Shipment{shipment}), nil
+	}
+
+	DebugPrintf("buying rate %s for shipment %s", rate.ID, shipment.ID)
+	shipment, err = easypostClient.BuyShipment(shipment.ID, &easypost.Rate{ID: rate.ID}, "")
+	if err != nil {
+		return nil, fmt.Errorf("error with buying shipment: %s", err.Error())
+	}
+	DebugPrintMarshalJson("order.completed.shipment.bought", shipment)
+
+	return NewLabelPurchase(order.Token, []*easypost.Shipment{shipment}), nil
+}
+
+// buyEasypostOrder buys the labels for every shipment of an EasyPost order
+// with the selected carrier and service
+func buyEasypostOrder(easypostClient *easypost.Client, orderToken string, orderId string, carrier string, service string) (*LabelPurchase, error) {
+	easypostOrder, err := easypostClient.GetOrder(orderId)
+	if err != nil {
+		return nil, fmt.Errorf("error with fetching order for selected rate: %s", err.Error())
+	}
+
+	if len(easypostOrder.Shipments) > 0 && isShipmentBought(easypostOrder.Shipments[0]) {
+		logJsonWithStatus(JsonLogStatusWarning, "order.completed", fmt.Sprintf("labels already purchased for %s, skipping", orderToken))
+		return NewLabelPurchase(orderToken, easypostOrder.Shipments), nil
+	}
+
+	DebugPrintf("buying %s %s for order %s", carrier, service, orderId)
+	easypostOrder, err = easypostClient.BuyOrder(orderId, carrier, service)
+	if err != nil {
+		return nil, fmt.Errorf("error with buying order: %s", err.Error())
+	}
+	DebugPrintMarshalJson("order.completed.order.bought", easypostOrder)
+
+	return NewLabelPurchase(orderToken, easypostOrder.Shipments), nil
+}
+
+// UpdateOrderTracking marks the Snipcart order as shipped and writes the
+// tracking number and carrier tracking URL of the purchased label back to it
+func UpdateOrderTracking(snipcartClient *snipcart.Client, purchase *LabelPurchase) error {
+	_, err := snipcartClient.UpdateOrder(purchase.OrderToken, &snipcart.OrderUpdate{
+		Status:         "Shipped",
+		TrackingNumber: strings.Join(purchase.TrackingCodes(), ", "),
+		TrackingUrl:    purchase.TrackingUrl(),
+	})
+	if err != nil {
+		return fmt.Errorf("error with updating order %s tracking: %s", purchase.OrderToken, err.Error())
+	}
+
+	return nil
+}
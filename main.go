@@ -9,6 +9,7 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"os"
 	"strings"
 	"time"
 
@@ -25,10 +26,25 @@ type WebhookEvent struct {
 	EventName string `json:"eventName"`
 }
 
+type WebhookDiscount struct {
+	Code        string  `json:"code"`
+	Type        string  `json:"type"`
+	AmountSaved float64 `json:"amountSaved"`
+}
+
+// WebhookOrder is a Snipcart order along with the fields of the webhook
+// content that snipcart.Order does not include
+type WebhookOrder struct {
+	snipcart.Order
+	ItemsTotal float64           `json:"itemsTotal"`
+	Discounts  []WebhookDiscount `json:"discounts"`
+	Lang       string            `json:"lang"`
+}
+
 type ShippingRateFetchWebhookEvent struct {
-	EventName string         `json:"eventName"`
-	CreatedOn time.Time      `json:"createdOn"`
-	Order     snipcart.Order `json:"content"`
+	EventName string       `json:"eventName"`
+	CreatedOn time.Time    `json:"createdOn"`
+	Order     WebhookOrder `json:"content"`
 }
 
 type OrderCompleteWebhookEvent struct {
@@ -37,6 +53,71 @@ type OrderCompleteWebhookEvent struct {
 	Order     snipcart.Order `json:"content"`
 }
 
+// prepareShipment verifies and sanitizes the shipment's destination and sets
+// its label date and international info before it is quoted, returning
+// shipping errors when the address cannot be shipped to as entered
+func prepareShipment(easypostClient *easypost.Client, shipment *easypost.Shipment, order *WebhookOrder, shipDate time.Time, locale *Locale) *snipcart.ShippingErrors {
+	// Verify the destination is deliverable, unless verification is known to
+	// give false negatives for it. Verification failing on EasyPost's side does
+	// not block checkout
+	if webhookConfig.VerifyAddress && !IsValidationWhitelisted(*shipment.ToAddress) {
+		ctx, cancel := QuoteContext()
+		verified, shippingErrors, err := VerifyShippingAddress(ctx, easypostClient, shipment.ToAddress, locale)
+		cancel()
+
+		if err != nil {
+			logJsonWithStatus(JsonLogStatusWarning, "shippingrates.fetch", fmt.Sprintf("skipping address verification for %s: %s", order.Token, err.Error()))
+		} else if shippingErrors != nil {
+			logJson("shippingrates.fetch", fmt.Sprintf("address verification failed for %s", order.Token))
+			return shippingErrors
+		} else if changes := DiffAddress(shipment.ToAddress, verified); len(changes) > 0 {
+			// Ship to the corrected address, optionally asking the customer to
+			// confirm it first
+			changesJson, _ := json.Marshal(changes)
+			logJson("shippingrates.fetch", fmt.Sprintf("address corrected for %s: %s", order.Token, string(changesJson)))
+
+			if webhookConfig.AddressSuggestions && !IsZIP4Only(changes) {
+				if suggestion := SuggestAddress(&order.Order, verified, locale); suggestion != nil {
+					return suggestion
+				}
+			}
+
+			ApplyVerifiedAddress(shipment.ToAddress, verified)
+		}
+	}
+
+	// Quote rates for the day the order will actually ship
+	shipment.Options = &easypost.ShipmentOptions{
+		LabelDate: &shipDate,
+	}
+
+	// Carriers reject non-Latin text and overlong lines on labels, so the label
+	// address is transliterated while the customer's original is kept as the
+	// shipment's reference. Addresses that cannot be written in ASCII within
+	// the carriers' line limits are never quoted
+	if webhookConfig.SanitizeAddress {
+		lineLimit := AddressLineLimit(webhookConfig, shipment.ToAddress.Country, shipment.ToAddress.State)
+
+		original, shippingErrors := SanitizeAddress(shipment.ToAddress, lineLimit, locale)
+		if shippingErrors != nil {
+			logJson("shippingrates.fetch", fmt.Sprintf("address for %s cannot be sanitized", order.Token))
+			return shippingErrors
+		}
+
+		if original != nil {
+			DebugPrintMarshalJson("shippingrates.fetch.original_address", original)
+			shipment.Reference = OriginalAddressReference(original)
+		}
+	}
+
+	// Set international info
+	if IsInternational(order.ShippingAddress.Country) {
+		SetInternationalInfo(shipment, order)
+	}
+
+	return nil
+}
+
 // HandleShippingRates goes through the order and creates a shipment, running
 // validations and adding information such as customs information on the way, or
 // uses an existing shipment to respond with a list of rates for Snipcart
@@ -53,14 +134,17 @@ func HandleShippingRates(body io.ReadCloser, easypostClient *easypost.Client) (a
 
 	DebugPrintMarshalJson("shippingrates.fetch.order", event.Order)
 
+	// Customer facing text is rendered in the customer's language
+	locale := NewLocale(event.Order.Lang)
+
 	// Validate Address Fields such as names being shorter than 2 letters, etc.
-	if err := ValidateAddressFields(event.Order.ShippingAddress, webhookConfig.Production); err != nil {
-		return err, nil
+	shippingErrors, deniedCarriers := ValidateAddressFields(event.Order.ShippingAddress, locale, webhookConfig.Production)
+	if shippingErrors != nil {
+		return shippingErrors, nil
 	}
 
-	parcel := webhookConfig.DefaultParcel
-	parcel.Weight = WeightGramToOunce(event.Order.TotalWeight)
-	DebugPrintMarshalJson("shippingrates.fetch.parcel", parcel)
+	parcels := OrderParcels(&event.Order.Order)
+	DebugPrintMarshalJson("shippingrates.fetch.parcels", parcels)
 
 	shipment := easypost.Shipment{
 		FromAddress: webhookConfig.SenderAddress,
@@ -76,7 +160,6 @@ func HandleShippingRates(body io.ReadCloser, easypostClient *easypost.Client) (a
 			Phone:   event.Order.ShippingAddress.Phone,
 			Email:   event.Order.Email,
 		},
-		Parcel: parcel,
 		TaxIdentifiers: []*easypost.TaxIdentifier{
 			{
 				Entity:         TAXENT_SENDER,
@@ -87,45 +170,73 @@ func HandleShippingRates(body io.ReadCloser, easypostClient *easypost.Client) (a
 		},
 	}
 	shipment.ReturnAddress = shipment.FromAddress
-	DebugPrintMarshalJson("shippingrates.fetch.shipment", shipment)
 
-	// Set international info
-	if IsInternational(event.Order.ShippingAddress.Country) {
-		SetInternationalInfo(&shipment, &event.Order)
-	}
+	// Quote rates for the day the order will actually ship
+	shipDate := ShipDate(time.Now())
 
-	// Create the shipping response object when creating a shipment
-	var shipmentResponse *easypost.Shipment
+	var quote *Quote
 
-	// Check if we already have a shipment, otherwise create a shipment
-	if event.Order.ShippingRateId != "" {
-		shipmentRate, err := easypostClient.GetRate(event.Order.ShippingRateId)
-		if err != nil {
-			return http.StatusInternalServerError, fmt.Errorf("error with fetching existing shipments rate: %s", err.Error())
-		}
+	// Check if we already have a shipment, otherwise create a shipment (or an
+	// order of shipments when packed into several parcels). Fallback rates have
+	// no shipment so are quoted again
+	if event.Order.ShippingRateId != "" && !IsFallbackRateId(event.Order.ShippingRateId) {
+		ctx, cancel := QuoteContext()
+		defer cancel()
 
-		shipmentResponse, err = easypostClient.GetShipment(shipmentRate.ShipmentID)
+		quote, err = ExistingQuote(ctx, easypostClient, event.Order.ShippingRateId)
 		if err != nil {
-			return http.StatusInternalServerError, fmt.Errorf("error with fetching existing shipment: %s", err.Error())
+			return fallbackOrError(&event.Order, err)
 		}
 	} else {
-		DebugPrintf("creating shipment")
-		shipmentResponse, err = easypostClient.CreateShipment(&shipment)
-		if err != nil {
-			return http.StatusInternalServerError, fmt.Errorf("error with creating shipment: %s", err.Error())
+		cacheKey := ShipmentCacheKey(&event.Order, shipDate)
+		quote = CachedQuote(cacheKey)
+
+		if quote == nil {
+			// Existing and cached quotes were made for an already checked
+			// address, so it is only verified and sanitized for new quotes
+			if shippingErrors := prepareShipment(easypostClient, &shipment, &event.Order, shipDate, locale); shippingErrors != nil {
+				return shippingErrors, nil
+			}
+			DebugPrintMarshalJson("shippingrates.fetch.shipment", shipment)
+
+			ctx, cancel := QuoteContext()
+			defer cancel()
+
+			quote, err = CreateQuote(ctx, easypostClient, &shipment, &event.Order.Order, parcels)
+			if err != nil {
+				return fallbackOrError(&event.Order, err)
+			}
+
+			CacheQuote(cacheKey, quote)
 		}
 	}
-	DebugPrintMarshalJson("shippingrates.fetch.shipment.created", shipmentResponse)
 
 	// Check any carrier messages
-	if len(shipmentResponse.Messages) > 0 {
-		DebugPrintf("WARNING Shipment messages: %v", shipmentResponse.Messages)
+	if len(quote.Messages) > 0 {
+		DebugPrintf("WARNING Shipment messages: %v", quote.Messages)
 	}
 
 	// Generate shipping rates
-	shippingRates, err := GenerateSnipcartRates(webhookConfig, shipmentResponse.Rates)
+	rateContext := NewRateContext(&event.Order)
+	rateContext.DeniedCarriers = deniedCarriers
+
+	// Estimate delivery from the date the rates were quoted to ship on, which
+	// for an existing quote can be earlier than today's ship date
+	quoteShipDate := shipDate
+	if !quote.ShipDate.IsZero() {
+		quoteShipDate = quote.ShipDate
+	}
+
+	shippingRates, err := GenerateSnipcartRates(webhookConfig, quote, quoteShipDate, rateContext, locale)
 	if err != nil {
-		return http.StatusInternalServerError, fmt.Errorf("error with creating shipment: %s", err.Error())
+		return fallbackOrError(&event.Order, fmt.Errorf("error with creating shipment: %s", err.Error()))
+	}
+
+	if len(shippingRates.Rates) == 0 {
+		if fallbackRates := FallbackShippingRates(&event.Order); fallbackRates != nil {
+			logJsonWithStatus(JsonLogStatusWarning, "shippingrates.fetch", fmt.Sprintf("using fallback rates for %s: no allowed rates", event.Order.Token))
+			return fallbackRates, nil
+		}
 	}
 
 	logJson("shippingrates.fetch", fmt.Sprintf("completed for %s", event.Order.Token))
@@ -133,32 +244,59 @@ func HandleShippingRates(body io.ReadCloser, easypostClient *easypost.Client) (a
 	return shippingRates, nil
 }
 
-// HandleOrderComplete handles the completion of the order and simply creates a
-// log message and a response code for Snipcart
-// TODO: Is this being used at all?
-func HandleOrderComplete(body io.ReadCloser) (int, error) {
+// HandleOrderComplete handles the completion of the order by buying the label
+// for the shipping rate the customer selected, returning the purchased label
+// and tracking information after writing it back to the Snipcart order
+func HandleOrderComplete(body io.ReadCloser, easypostClient *easypost.Client, snipcartClient *snipcart.Client) (*LabelPurchase, error) {
 	var event OrderCompleteWebhookEvent
 	if err := json.NewDecoder(body).Decode(&event); err != nil {
-		return http.StatusInternalServerError, fmt.Errorf("error with ordercomplete event decode: %s", err.Error())
+		return nil, fmt.Errorf("error with ordercomplete event decode: %s", err.Error())
 	}
 
 	logJson("order.completed", event.Order.Token)
 
-	if !webhookConfig.Production {
-		jsonEvent, _ := json.Marshal(event)
-		DebugPrintln(string(jsonEvent))
+	DebugPrintMarshalJson("order.completed.order", event.Order)
+
+	// Orders without shippable items (i.e. digital goods) have no rate to buy
+	if event.Order.ShippingRateId == "" {
+		logJsonWithStatus(JsonLogStatusWarning, "order.completed", fmt.Sprintf("no shipping rate for %s, skipping label purchase", event.Order.Token))
+		return nil, nil
+	}
+
+	// Fallback rates were quoted without EasyPost, so the label must be bought
+	// by hand
+	if IsFallbackRateId(event.Order.ShippingRateId) {
+		logJsonWithStatus(JsonLogStatusWarning, "order.completed", fmt.Sprintf("fallback rate %s selected for %s, label must be purchased manually", event.Order.ShippingRateId, event.Order.Token))
+		return nil, nil
+	}
+
+	purchase, err := BuyOrderLabel(easypostClient, &event.Order)
+	if err != nil {
+		return nil, err
+	}
+
+	logJson("order.completed", fmt.Sprintf("purchased %d labels for %s tracking %s", len(purchase.Labels), event.Order.Token, strings.Join(purchase.TrackingCodes(), ", ")))
+
+	// Returning an error here has Snipcart retry the webhook, which will reuse
+	// the already purchased label and only retry the tracking update
+	if err := UpdateOrderTracking(snipcartClient, purchase); err != nil {
+		return nil, err
 	}
 
-	return http.StatusOK, nil
+	logJson("order.completed", fmt.Sprintf("updated tracking for %s", event.Order.Token))
+
+	return purchase, nil
 }
 
 // HandleTaxCalculation returns a list of taxes that need to be applied to an
 // existing order as part of checkout for customers. This primarily has to do
-// with international Value Added Tax, but may pertain to sales tax as well.
+// with international Value Added Tax, and US sales tax for configured nexus
+// states.
 func HandleTaxCalculation(body io.ReadCloser) (*snipcart.TaxResponse, error) {
-	var taxes snipcart.TaxResponse
+	// Snipcart expects a list even when no taxes apply
+	taxes := snipcart.TaxResponse{Taxes: []snipcart.Tax{}}
 
-	var event snipcart.TaxWebhook
+	var event TaxCalculateWebhookEvent
 	if err := json.NewDecoder(body).Decode(&event); err != nil {
 		return &taxes, fmt.Errorf("error with taxescalculate event decode: %s", err.Error())
 	}
@@ -173,24 +311,13 @@ func HandleTaxCalculation(body io.ReadCloser) (*snipcart.TaxResponse, error) {
 
 	DebugPrintf("successfully decoded webhook tax POST content -- state %s country %s", taxAddress.Province, taxAddress.Country)
 
-	/* Tax - EU */
-	if IsEUCountry(taxAddress.Country) {
-		DebugPrintf("detected EU country for Tax calculation: %s", taxAddress.Country)
+	/* Tax - EU IOSS, UK VAT, and Norway VOEC */
+	if scheme := ImportVATSchemeFor(taxAddress.Country); scheme != nil {
+		DebugPrintf("detected %s country for Tax calculation: %s", scheme.Name, taxAddress.Country)
 
-		taxes.Taxes = append(taxes.Taxes, snipcart.Tax{
-			Name:             "VAT",
-			Amount:           event.Content.ItemsTotal * EUCountryVAT[strings.ToLower(taxAddress.Country)],
-			NumberForInvoice: fmt.Sprintf("%s - %d%%", strings.ToUpper(taxAddress.Country), int(EUCountryVAT[strings.ToLower(taxAddress.Country)]*100)),
-			Rate:             EUCountryVAT[strings.ToLower(taxAddress.Country)],
-		})
-	} else {
-		// TODO: Make this customizable in the future? Not everyone is from NH
-		taxes.Taxes = append(taxes.Taxes, snipcart.Tax{
-			Name:             "NH Sales Tax",
-			Amount:           0.0,
-			NumberForInvoice: "0%",
-			Rate:             0.0,
-		})
+		taxes.Taxes = append(taxes.Taxes, ImportVATTaxes(&event.Content, taxAddress, event.Content.CreationDate, scheme)...)
+	} else if !IsInternational(taxAddress.Country) {
+		taxes.Taxes = append(taxes.Taxes, SalesTaxes(&event.Content, taxAddress)...)
 	}
 
 	DebugPrintf("finalized tax calculation for order %s", event.Content.Token)
@@ -224,17 +351,20 @@ func RouteSnipcartWebhook(easypostClient *easypost.Client, snipcartClient *snipc
 		}
 
 		switch event.EventName {
-		/*
-			// TODO: Why does this no longer exist?
-			case "order.completed":
-				statusCode, err := HandleOrderComplete(io.NopCloser(bytes.NewBuffer(rawBody)), easypostClient)
-				if err != nil {
-					c.AbortWithError(statusCode, err)
-					return
-				}
+		case "order.completed":
+			response, err := HandleOrderComplete(ioutil.NopCloser(bytes.NewBuffer(rawBody)), easypostClient, snipcartClient)
+			if err != nil {
+				logJsonWithStatus(JsonLogStatusError, "ORDER ERROR", err.Error())
+				c.AbortWithError(http.StatusInternalServerError, err)
+				return
+			}
+
+			if response == nil {
+				c.JSON(http.StatusOK, gin.H{})
+				return
+			}
 
-				c.Data(statusCode, gin.MIMEHTML, nil)
-		*/
+			c.JSON(http.StatusOK, response)
 		case "shippingrates.fetch":
 			response, err := HandleShippingRates(ioutil.NopCloser(bytes.NewBuffer(rawBody)), easypostClient)
 			if err != nil {
@@ -267,7 +397,20 @@ func init() {
 	var err error
 	webhookConfig, err = config.NewConfigFromEnv(false)
 	if err != nil {
-		DebugPrintf("[ERROR] %s", err.Error())
+		logJsonWithStatus(JsonLogStatusError, "config", err.Error())
+		return
+	}
+
+	if err := CheckAddressRules(webhookConfig.AddressRules); err != nil {
+		logJsonWithStatus(JsonLogStatusError, "config", err.Error())
+		return
+	}
+
+	WarnMissingImportVATIds(webhookConfig)
+
+	shipmentCache, err = NewShipmentCacheFromConfig(webhookConfig)
+	if err != nil {
+		logJsonWithStatus(JsonLogStatusError, "config", err.Error())
 		return
 	}
 
@@ -290,13 +433,30 @@ func init() {
 	})
 	r.POST("/webhooks/snipcart", RouteSnipcartWebhook(easypostClient, snipcartClient))
 
+	ginEngine = r
 	ginLambda = ginadapter.New(r)
 }
 
 func Handler(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Configuration errors are logged on init, so respond with an error rather
+	// than panic on every invocation
+	if ginLambda == nil {
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, errors.New("webhook router was not initialized, check configuration")
+	}
+
 	return ginLambda.ProxyWithContext(ctx, req)
 }
 
 func main() {
+	// Run as a standalone HTTP server when invoked with the serve command,
+	// otherwise assume we are running inside AWS Lambda
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		if err := ServeCommand(os.Args[2:]); err != nil {
+			logJsonWithStatus(JsonLogStatusError, "server", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
 	lambda.Start(Handler)
 }
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/EasyPost/easypost-go/v4"
+	"github.com/debyltech/go-snipcart-webhook/config"
+	"github.com/debyltech/go-snipcart/snipcart"
+)
+
+// PackItem is a single unit of an order item to be packed, with dimensions in
+// inches and weight in ounces. Item is the order item the unit is from
+type PackItem struct {
+	Name   string
+	Length float64
+	Width  float64
+	Height float64
+	Weight float64
+	Item   *snipcart.Item `json:"-"`
+}
+
+// PackedParcel is a box and the items packed into it. Box is nil for items
+// that do not fit in any of the configured boxes and ship in their own
+// packaging
+type PackedParcel struct {
+	Box    *config.Box
+	Items  []PackItem
+	Weight float64
+	Volume float64
+}
+
+func (i *PackItem) Volume() float64 {
+	return i.Length * i.Width * i.Height
+}
+
+// DimensionCentimeterToInch converts a dimension in centimeters, as used by
+// Snipcart, to inches, rounded to two decimal points
+func DimensionCentimeterToInch(dimensionInCentimeters float64) float64 {
+	return math.Round((dimensionInCentimeters/2.54)*100) / 100
+}
+
+// sortedDimensions returns the dimensions from largest to smallest so that
+// items can be compared to boxes in any orientation
+func sortedDimensions(length float64, width float64, height float64) [3]float64 {
+	dimensions := []float64{length, width, height}
+	sort.Sort(sort.Reverse(sort.Float64Slice(dimensions)))
+
+	return [3]float64{dimensions[0], dimensions[1], dimensions[2]}
+}
+
+// boxFitsItem reports whether the item fits in the box in some orientation
+func boxFitsItem(box *config.Box, item *PackItem) bool {
+	boxDimensions := sortedDimensions(box.Length, box.Width, box.Height)
+	itemDimensions := sortedDimensions(item.Length, item.Width, item.Height)
+
+	for i := range boxDimensions {
+		if itemDimensions[i] > boxDimensions[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// boxTareWeight returns the box's tare weight in ounces, as items are packed
+// by their weight in ounces
+func boxTareWeight(box *config.Box) float64 {
+	return WeightGramToOunce(box.TareWeight)
+}
+
+// boxMaxWeight returns the box's max weight in ounces
+func boxMaxWeight(box *config.Box) float64 {
+	return WeightGramToOunce(box.MaxWeight)
+}
+
+// boxHasRoom reports whether the item fits in the box along with the items
+// already packed in the parcel. Volume is used as an approximation of the space
+// left in the box
+func boxHasRoom(box *config.Box, parcel *PackedParcel, item *PackItem) bool {
+	if !boxFitsItem(box, item) {
+		return false
+	}
+
+	if parcel.Volume+item.Volume() > box.Volume() {
+		return false
+	}
+
+	if box.MaxWeight > 0 && boxTareWeight(box)+parcel.Weight+item.Weight > boxMaxWeight(box) {
+		return false
+	}
+
+	return true
+}
+
+func (p *PackedParcel) add(item PackItem) {
+	p.Items = append(p.Items, item)
+	p.Weight += item.Weight
+	p.Volume += item.Volume()
+}
+
+// ItemPackagingWeight returns the packaging overhead in grams of one unit of
+// the item, from its packaging_weight custom field or the configured default
+func ItemPackagingWeight(item *snipcart.Item) float64 {
+	if value := ItemCustomField(item, "packaging_weight"); value != "" {
+		weight, err := strconv.ParseFloat(value, 64)
+		if err == nil {
+			return weight
+		}
+
+		logJsonWithStatus(JsonLogStatusWarning, "shippingrates.fetch", fmt.Sprintf("item %s has invalid packaging_weight '%s'", item.Name, value))
+	}
+
+	return webhookConfig.ItemPackagingWeight
+}
+
+// PackOrderItems creates a PackItem for each unit of the order's shippable
+// items, including the packaging overhead of each unit in its weight
+func PackOrderItems(order *snipcart.Order) []PackItem {
+	var items []PackItem
+
+	for i := range order.Items {
+		v := &order.Items[i]
+		if !v.Shippable {
+			continue
+		}
+
+		packagingWeight := ItemPackagingWeight(v)
+
+		for j := 0; j < v.Quantity; j++ {
+			items = append(items, PackItem{
+				Name:   v.Name,
+				Length: DimensionCentimeterToInch(v.Length),
+				Width:  DimensionCentimeterToInch(v.Width),
+				Height: DimensionCentimeterToInch(v.Height),
+				Weight: WeightGramToOunce(v.Weight + packagingWeight),
+				Item:   v,
+			})
+		}
+	}
+
+	return items
+}
+
+// PackItems packs the items into the boxes, which are sorted from smallest to
+// largest, using first fit decreasing: the largest items are placed first into
+// the first open parcel with room, otherwise into the smallest box that fits
+// them. Each parcel is then moved to the smallest box that still holds its
+// items
+func PackItems(boxes []config.Box, items []PackItem) []*PackedParcel {
+	sortedItems := make([]PackItem, len(items))
+	copy(sortedItems, items)
+	sort.SliceStable(sortedItems, func(i, j int) bool {
+		if sortedItems[i].Volume() == sortedItems[j].Volume() {
+			return sortedItems[i].Weight > sortedItems[j].Weight
+		}
+		return sortedItems[i].Volume() > sortedItems[j].Volume()
+	})
+
+	var parcels []*PackedParcel
+
+	for _, item := range sortedItems {
+		packed := false
+
+		for _, parcel := range parcels {
+			if parcel.Box != nil && boxHasRoom(parcel.Box, parcel, &item) {
+				parcel.add(item)
+				packed = true
+				break
+			}
+		}
+
+		if packed {
+			continue
+		}
+
+		parcel := &PackedParcel{}
+		for i := range boxes {
+			if boxHasRoom(&boxes[i], parcel, &item) {
+				parcel.Box = &boxes[i]
+				break
+			}
+		}
+
+		if parcel.Box == nil {
+			logJsonWithStatus(JsonLogStatusWarning, "shippingrates.fetch", fmt.Sprintf("item %s does not fit any box, shipping separately", item.Name))
+		}
+
+		parcel.add(item)
+		parcels = append(parcels, parcel)
+	}
+
+	// Downsize parcels that ended up with room to spare
+	for _, parcel := range parcels {
+		if parcel.Box == nil {
+			continue
+		}
+
+		for i := range boxes {
+			if boxHoldsParcel(&boxes[i], parcel) {
+				parcel.Box = &boxes[i]
+				break
+			}
+		}
+	}
+
+	return parcels
+}
+
+// boxHoldsParcel reports whether all of the parcel's items fit in the box
+func boxHoldsParcel(box *config.Box, parcel *PackedParcel) bool {
+	for i := range parcel.Items {
+		if !boxFitsItem(box, &parcel.Items[i]) {
+			return false
+		}
+	}
+
+	if parcel.Volume > box.Volume() {
+		return false
+	}
+
+	return box.MaxWeight <= 0 || boxTareWeight(box)+parcel.Weight <= boxMaxWeight(box)
+}
+
+// Parcel converts the packed parcel to an EasyPost parcel, with the weight of
+// the box included
+func (p *PackedParcel) Parcel() *easypost.Parcel {
+	tareWeight := WeightGramToOunce(webhookConfig.ParcelTareWeight)
+	if p.Box != nil {
+		tareWeight = boxTareWeight(p.Box)
+	}
+
+	parcel := easypost.Parcel{
+		Weight: math.Round((p.Weight+tareWeight)*100) / 100,
+	}
+
+	if p.Box != nil {
+		parcel.Length = p.Box.Length
+		parcel.Width = p.Box.Width
+		parcel.Height = p.Box.Height
+	} else if len(p.Items) > 0 {
+		parcel.Length = p.Items[0].Length
+		parcel.Width = p.Items[0].Width
+		parcel.Height = p.Items[0].Height
+	}
+
+	return &parcel
+}
+
+// DefaultOrderParcel returns the default parcel with the weight of the whole
+// order, including its packaging
+func DefaultOrderParcel(order *snipcart.Order) *easypost.Parcel {
+	packagingWeight := 0.0
+	for _, v := range order.Items {
+		if v.Shippable {
+			packagingWeight += ItemPackagingWeight(&v) * float64(v.Quantity)
+		}
+	}
+
+	parcel := *webhookConfig.DefaultParcel
+	parcel.Weight = WeightGramToOunce(order.TotalWeight + packagingWeight + webhookConfig.ParcelTareWeight)
+
+	return &parcel
+}
+
+// OrderParcel is a parcel to quote for the order along with the units of the
+// order's items packed in it, which are declared on its customs
+type OrderParcel struct {
+	Parcel *easypost.Parcel
+	Items  []PackItem
+}
+
+// OrderParcels returns the parcels to quote for the order. Without any boxes
+// configured the whole order is quoted as the default parcel
+func OrderParcels(order *snipcart.Order) []*OrderParcel {
+	if len(webhookConfig.Boxes) == 0 {
+		return []*OrderParcel{{Parcel: DefaultOrderParcel(order), Items: PackOrderItems(order)}}
+	}
+
+	var parcels []*OrderParcel
+	for _, packed := range PackItems(webhookConfig.Boxes, PackOrderItems(order)) {
+		parcels = append(parcels, &OrderParcel{Parcel: packed.Parcel(), Items: packed.Items})
+	}
+
+	// Orders without any shippable items still need a parcel to quote
+	if len(parcels) == 0 {
+		parcels = append(parcels, &OrderParcel{Parcel: DefaultOrderParcel(order)})
+	}
+
+	return parcels
+}
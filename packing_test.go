@@ -0,0 +1,106 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/debyltech/go-snipcart-webhook/config"
+)
+
+func TestPackItems(t *testing.T) {
+	webhookConfig = &config.Config{Production: true}
+
+	boxes := []config.Box{
+		{Name: "small", Length: 6, Width: 6, Height: 6, MaxWeight: 1000},
+		{Name: "large", Length: 12, Width: 12, Height: 12, MaxWeight: 5000},
+	}
+
+	cube := func(name string, side float64, weight float64) PackItem {
+		return PackItem{Name: name, Length: side, Width: side, Height: side, Weight: weight}
+	}
+
+	tests := []struct {
+		name      string
+		items     []PackItem
+		wantBoxes []string
+		wantItems []int
+	}{
+		{
+			name:      "small items share the smallest box",
+			items:     []PackItem{cube("a", 3, 5), cube("b", 3, 5)},
+			wantBoxes: []string{"small"},
+			wantItems: []int{2},
+		},
+		{
+			name:      "rotated item fits the smallest box",
+			items:     []PackItem{{Name: "flat", Length: 2, Width: 6, Height: 3, Weight: 5}},
+			wantBoxes: []string{"small"},
+			wantItems: []int{1},
+		},
+		{
+			name:      "large item uses the large box",
+			items:     []PackItem{{Name: "long", Length: 10, Width: 5, Height: 5, Weight: 5}},
+			wantBoxes: []string{"large"},
+			wantItems: []int{1},
+		},
+		{
+			name:      "oversized item ships in its own packaging",
+			items:     []PackItem{cube("a", 3, 5), {Name: "pole", Length: 20, Width: 4, Height: 4, Weight: 20}},
+			wantBoxes: []string{"", "small"},
+			wantItems: []int{1, 1},
+		},
+		{
+			name:      "max weight splits parcels",
+			items:     []PackItem{cube("a", 4, 15), cube("b", 4, 15), cube("c", 4, 15)},
+			wantBoxes: []string{"small", "small"},
+			wantItems: []int{2, 1},
+		},
+		{
+			name:      "volume overflow opens another box",
+			items:     []PackItem{cube("a", 5, 5), cube("b", 5, 5)},
+			wantBoxes: []string{"small", "small"},
+			wantItems: []int{1, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parcels := PackItems(boxes, tt.items)
+
+			var gotBoxes []string
+			var gotItems []int
+			for _, parcel := range parcels {
+				name := ""
+				if parcel.Box != nil {
+					name = parcel.Box.Name
+				}
+				gotBoxes = append(gotBoxes, name)
+				gotItems = append(gotItems, len(parcel.Items))
+			}
+
+			if !reflect.DeepEqual(gotBoxes, tt.wantBoxes) {
+				t.Errorf("boxes = %v, want %v", gotBoxes, tt.wantBoxes)
+			}
+
+			if !reflect.DeepEqual(gotItems, tt.wantItems) {
+				t.Errorf("items per parcel = %v, want %v", gotItems, tt.wantItems)
+			}
+		})
+	}
+}
+
+func TestPackedParcelTareWeight(t *testing.T) {
+	webhookConfig = &config.Config{Production: true, ParcelTareWeight: 283.5}
+
+	box := &config.Box{Name: "small", Length: 6, Width: 6, Height: 6, TareWeight: 567}
+
+	boxed := (&PackedParcel{Box: box, Weight: 10}).Parcel()
+	if boxed.Weight != 30 {
+		t.Errorf("boxed parcel weight = %f, want 30", boxed.Weight)
+	}
+
+	unboxed := (&PackedParcel{Items: []PackItem{{Length: 1, Width: 1, Height: 1}}, Weight: 10}).Parcel()
+	if unboxed.Weight != 20 {
+		t.Errorf("unboxed parcel weight = %f, want 20", unboxed.Weight)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// PostalCodeFormat is the format of a country's postal codes with an example
+// shown to customers. Optional postal codes are only checked when entered
+type PostalCodeFormat struct {
+	Pattern  *regexp.Regexp
+	Example  string
+	Optional bool
+}
+
+var (
+	// PostalCodeFormats are matched against the upper case postal code, and
+	// countries without one either have no postal codes or are not checked
+	PostalCodeFormats map[string]PostalCodeFormat = map[string]PostalCodeFormat{
+		"US": {Pattern: regexp.MustCompile(`^\d{5}(-?\d{4})?$`), Example: "12345"},
+		"CA": {Pattern: regexp.MustCompile(`^[A-Z]\d[A-Z] ?\d[A-Z]\d$`), Example: "K1A 0B1"},
+		"MX": {Pattern: regexp.MustCompile(`^\d{5}$`), Example: "01000"},
+		"GB": {Pattern: regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? ?\d[A-Z]{2}$`), Example: "SW1A 1AA"},
+		"IE": {Pattern: regexp.MustCompile(`^[A-Z]\d[\dW] ?[A-Z\d]{4}$`), Example: "D02 X285", Optional: true},
+		"AT": {Pattern: regexp.MustCompile(`^\d{4}$`), Example: "1010"},
+		"BE": {Pattern: regexp.MustCompile(`^\d{4}$`), Example: "1000"},
+		"CH": {Pattern: regexp.MustCompile(`^\d{4}$`), Example: "8001"},
+		"CZ": {Pattern: regexp.MustCompile(`^\d{3} ?\d{2}$`), Example: "110 00"},
+		"DE": {Pattern: regexp.MustCompile(`^\d{5}$`), Example: "10115"},
+		"DK": {Pattern: regexp.MustCompile(`^\d{4}$`), Example: "1050"},
+		"ES": {Pattern: regexp.MustCompile(`^\d{5}$`), Example: "28001"},
+		"FI": {Pattern: regexp.MustCompile(`^\d{5}$`), Example: "00100"},
+		"FR": {Pattern: regexp.MustCompile(`^\d{5}$`), Example: "75001"},
+		"GR": {Pattern: regexp.MustCompile(`^\d{3} ?\d{2}$`), Example: "104 31"},
+		"HU": {Pattern: regexp.MustCompile(`^\d{4}$`), Example: "1011"},
+		"IT": {Pattern: regexp.MustCompile(`^\d{5}$`), Example: "00118"},
+		"LU": {Pattern: regexp.MustCompile(`^(L-)?\d{4}$`), Example: "1111"},
+		"NL": {Pattern: regexp.MustCompile(`^\d{4} ?[A-Z]{2}$`), Example: "1011 AB"},
+		"NO": {Pattern: regexp.MustCompile(`^\d{4}$`), Example: "0150"},
+		"PL": {Pattern: regexp.MustCompile(`^\d{2}-\d{3}$`), Example: "00-001"},
+		"PT": {Pattern: regexp.MustCompile(`^\d{4}-\d{3}$`), Example: "1000-001"},
+		"RO": {Pattern: regexp.MustCompile(`^\d{6}$`), Example: "010011"},
+		"SE": {Pattern: regexp.MustCompile(`^\d{3} ?\d{2}$`), Example: "114 55"},
+		"SK": {Pattern: regexp.MustCompile(`^\d{3} ?\d{2}$`), Example: "811 01"},
+		"AU": {Pattern: regexp.MustCompile(`^\d{4}$`), Example: "2000"},
+		"NZ": {Pattern: regexp.MustCompile(`^\d{4}$`), Example: "6011"},
+		"JP": {Pattern: regexp.MustCompile(`^\d{3}-?\d{4}$`), Example: "100-0001"},
+		"IN": {Pattern: regexp.MustCompile(`^\d{6}$`), Example: "110001"},
+		"BR": {Pattern: regexp.MustCompile(`^\d{5}-?\d{3}$`), Example: "01000-000"},
+	}
+
+	// StateRequiredCountries are the countries whose addresses carriers require
+	// a state or province for
+	StateRequiredCountries []string = []string{"US", "CA", "AU", "MX", "BR", "IN"}
+)
+
+// PostalCodeFormatFor returns the postal code format of the country, if it
+// has one
+func PostalCodeFormatFor(country string) (PostalCodeFormat, bool) {
+	format, ok := PostalCodeFormats[strings.ToUpper(country)]
+	return format, ok
+}
+
+// IsStateRequired reports whether addresses in the country need a state or
+// province
+func IsStateRequired(country string) bool {
+	return slices.Contains(StateRequiredCountries, strings.ToUpper(country))
+}
+
+// NormalizePostalCode trims and upper cases the postal code for matching
+func NormalizePostalCode(postalCode string) string {
+	return strings.ToUpper(strings.TrimSpace(postalCode))
+}
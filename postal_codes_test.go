@@ -0,0 +1,72 @@
+package main
+
+import "testing"
+
+func TestPostalCodeFormats(t *testing.T) {
+	tests := []struct {
+		country string
+		valid   string
+		invalid string
+	}{
+		{"US", "90210-1234", "9021"},
+		{"CA", "m5v3l9", "M5V 3L"},
+		{"MX", "06700", "6700"},
+		{"GB", "ec1a 1bb", "EC1A 1B"},
+		{"IE", "A65F4E2", "A65 F4E"},
+		{"AT", "5020", "50200"},
+		{"BE", "2000", "200"},
+		{"CH", "3011", "CH-3011"},
+		{"CZ", "60200", "602 0"},
+		{"DE", "80331", "8033"},
+		{"DK", "8000", "DK-8000"},
+		{"ES", "08001", "0800"},
+		{"FI", "33100", "3310"},
+		{"FR", "13001", "1300"},
+		{"GR", "54624", "546 2"},
+		{"HU", "6720", "67200"},
+		{"IT", "20121", "2012"},
+		{"LU", "l-1009", "L1009"},
+		{"NL", " 3511ab ", "3511 A"},
+		{"NO", "5003", "50033"},
+		{"PL", "31-042", "31042"},
+		{"PT", "4000-322", "4000322"},
+		{"RO", "400001", "40000"},
+		{"SE", "41101", "411-01"},
+		{"SK", "04001", "040 0"},
+		{"AU", "3000", "300"},
+		{"NZ", "1010", "10100"},
+		{"JP", "5300001", "530-001"},
+		{"IN", "560001", "56000"},
+		{"BR", "20040020", "20040-02"},
+	}
+
+	covered := make(map[string]bool)
+	for _, tt := range tests {
+		covered[tt.country] = true
+
+		t.Run(tt.country, func(t *testing.T) {
+			format, ok := PostalCodeFormatFor(tt.country)
+			if !ok {
+				t.Fatalf("no postal code format for %s", tt.country)
+			}
+
+			if !format.Pattern.MatchString(NormalizePostalCode(format.Example)) {
+				t.Errorf("example %q does not match", format.Example)
+			}
+
+			if !format.Pattern.MatchString(NormalizePostalCode(tt.valid)) {
+				t.Errorf("valid %q does not match", tt.valid)
+			}
+
+			if format.Pattern.MatchString(NormalizePostalCode(tt.invalid)) {
+				t.Errorf("invalid %q matches", tt.invalid)
+			}
+		})
+	}
+
+	for country := range PostalCodeFormats {
+		if !covered[country] {
+			t.Errorf("no test for %s postal codes", country)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/EasyPost/easypost-go/v4"
+	"github.com/debyltech/go-snipcart/snipcart"
+)
+
+// OrderRateIdSeparator separates the parts of the rate IDs given to Snipcart
+// for rates of EasyPost orders, which are bought by carrier and service rather
+// than by rate ID
+const OrderRateIdSeparator string = "|"
+
+// Quote is the set of rates quoted for a Snipcart order, either from a single
+// EasyPost shipment or from an EasyPost order when the items are packed into
+// more than one parcel
+type Quote struct {
+	ShipmentId string
+	OrderId    string
+	Rates      []*easypost.Rate
+	Messages   []*easypost.CarrierMessage
+	// ShipDate is the label date the shipments were created with, which is
+	// zero when EasyPost did not return one
+	ShipDate time.Time
+}
+
+// labelDate returns the label date of the shipment options, if any
+func labelDate(options *easypost.ShipmentOptions) time.Time {
+	if options == nil || options.LabelDate == nil {
+		return time.Time{}
+	}
+
+	return *options.LabelDate
+}
+
+// OrderRateId creates the rate ID given to Snipcart for a rate of an EasyPost
+// order
+func OrderRateId(orderId string, carrier string, service string) string {
+	return strings.Join([]string{orderId, carrier, service}, OrderRateIdSeparator)
+}
+
+// ParseOrderRateId returns the EasyPost order ID, carrier, and service of a
+// rate ID created by OrderRateId, and false for any other rate ID
+func ParseOrderRateId(rateId string) (string, string, string, bool) {
+	parts := strings.SplitN(rateId, OrderRateIdSeparator, 3)
+	if len(parts) != 3 {
+		return "", "", "", false
+	}
+
+	return parts[0], parts[1], parts[2], true
+}
+
+// RateId returns the rate ID given to Snipcart for the rate
+func (q *Quote) RateId(rate *easypost.Rate) string {
+	if q.OrderId != "" {
+		return OrderRateId(q.OrderId, rate.Carrier, rate.Service)
+	}
+
+	return rate.ID
+}
+
+// QuoteContext returns the context for creating or fetching quotes, which times
+// out after the configured rate timeout so fallback rates can be used instead
+func QuoteContext() (context.Context, context.CancelFunc) {
+	if webhookConfig.RateTimeout > 0 {
+		return context.WithTimeout(context.Background(), webhookConfig.RateTimeout)
+	}
+
+	return context.WithCancel(context.Background())
+}
+
+// CreateQuote creates an EasyPost shipment to quote a single parcel, or an
+// EasyPost order with a shipment for each parcel. The shipment is used as the
+// template for the addresses, reference, customs, and options of every
+// parcel, with the customs items of each parcel limited to the items packed in
+// it
+func CreateQuote(ctx context.Context, easypostClient *easypost.Client, shipment *easypost.Shipment, snipcartOrder *snipcart.Order, parcels []*OrderParcel) (*Quote, error) {
+	if len(parcels) == 1 {
+		shipment.Parcel = parcels[0].Parcel
+
+		DebugPrintf("creating shipment")
+		shipmentResponse, err := easypostClient.CreateShipmentWithContext(ctx, shipment)
+		if err != nil {
+			return nil, fmt.Errorf("error with creating shipment: %s", err.Error())
+		}
+		DebugPrintMarshalJson("shippingrates.fetch.shipment.created", shipmentResponse)
+
+		return &Quote{
+			ShipmentId: shipmentResponse.ID,
+			Rates:      shipmentResponse.Rates,
+			Messages:   shipmentResponse.Messages,
+			ShipDate:   labelDate(shipment.Options),
+		}, nil
+	}
+
+	order := easypost.Order{
+		ToAddress:     shipment.ToAddress,
+		FromAddress:   shipment.FromAddress,
+		ReturnAddress: shipment.ReturnAddress,
+		Reference:     shipment.Reference,
+	}
+
+	for _, parcel := range parcels {
+		parcelShipment := &easypost.Shipment{
+			Parcel:         parcel.Parcel,
+			Options:        shipment.Options,
+			TaxIdentifiers: shipment.TaxIdentifiers,
+		}
+
+		if shipment.CustomsInfo != nil {
+			customsInfo := *shipment.CustomsInfo
+			customsInfo.CustomsItems = ParcelCustomsItems(snipcartOrder, parcel.Items)
+			parcelShipment.CustomsInfo = &customsInfo
+		}
+
+		order.Shipments = append(order.Shipments, parcelShipment)
+	}
+
+	DebugPrintf("creating order with %d shipments", len(order.Shipments))
+	orderResponse, err := easypostClient.CreateOrderWithContext(ctx, &order)
+	if err != nil {
+		return nil, fmt.Errorf("error with creating order: %s", err.Error())
+	}
+	DebugPrintMarshalJson("shippingrates.fetch.order.created", orderResponse)
+
+	return &Quote{
+		OrderId:  orderResponse.ID,
+		Rates:    orderResponse.Rates,
+		Messages: orderResponse.Messages,
+		ShipDate: labelDate(shipment.Options),
+	}, nil
+}
+
+// ExistingQuote fetches the shipment or order that a previously quoted rate ID
+// belongs to, along with the label date it was quoted for
+func ExistingQuote(ctx context.Context, easypostClient *easypost.Client, rateId string) (*Quote, error) {
+	if orderId, _, _, ok := ParseOrderRateId(rateId); ok {
+		order, err := easypostClient.GetOrderWithContext(ctx, orderId)
+		if err != nil {
+			return nil, fmt.Errorf("error with fetching existing order: %s", err.Error())
+		}
+
+		quote := &Quote{
+			OrderId:  order.ID,
+			Rates:    order.Rates,
+			Messages: order.Messages,
+		}
+		if len(order.Shipments) > 0 {
+			quote.ShipDate = labelDate(order.Shipments[0].Options)
+		}
+
+		return quote, nil
+	}
+
+	shipmentRate, err := easypostClient.GetRateWithContext(ctx, rateId)
+	if err != nil {
+		return nil, fmt.Errorf("error with fetching existing shipments rate: %s", err.Error())
+	}
+
+	shipment, err := easypostClient.GetShipmentWithContext(ctx, shipmentRate.ShipmentID)
+	if err != nil {
+		return nil, fmt.Errorf("error with fetching existing shipment: %s", err.Error())
+	}
+
+	return &Quote{
+		ShipmentId: shipment.ID,
+		Rates:      shipment.Rates,
+		Messages:   shipment.Messages,
+		ShipDate:   labelDate(shipment.Options),
+	}, nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/EasyPost/easypost-go/v4"
+	"github.com/debyltech/go-snipcart-webhook/config"
+	"github.com/debyltech/go-snipcart/snipcart"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	// CarrierAddressLineLimits are the longest address lines carriers accept on
+	// labels, keyed by EasyPost carrier name
+	CarrierAddressLineLimits map[string]int = map[string]int{
+		"USPS":       47,
+		"UPS":        35,
+		"FedEx":      35,
+		"DHLExpress": 45,
+		"CanadaPost": 44,
+	}
+
+	// transliterations are for lower case letters that do not decompose into
+	// an ASCII letter and a diacritic, which covers Cyrillic and Greek
+	transliterations map[rune]string = map[rune]string{
+		// Latin
+		'ß': "ss", 'æ': "ae", 'ø': "o", 'œ': "oe", 'ł': "l", 'đ': "d", 'ð': "d",
+		'þ': "th", 'ı': "i",
+
+		// Cyrillic
+		'а': "a", 'б': "b", 'в': "v", 'г': "g", 'ґ': "g", 'д': "d", 'е': "e",
+		'ё': "yo", 'є': "ye", 'ж': "zh", 'з': "z", 'и': "i", 'і': "i", 'ї': "yi",
+		'й': "y", 'ј': "j", 'к': "k", 'л': "l", 'љ': "lj", 'м': "m", 'н': "n",
+		'њ': "nj", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'ћ': "c",
+		'ђ': "dj", 'у': "u", 'ў': "u", 'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch",
+		'џ': "dz", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e",
+		'ю': "yu", 'я': "ya",
+
+		// Greek
+		'α': "a", 'ά': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'έ': "e",
+		'ζ': "z", 'η': "i", 'ή': "i", 'θ': "th", 'ι': "i", 'ί': "i", 'ϊ': "i",
+		'ΐ': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x", 'ο': "o",
+		'ό': "o", 'π': "p", 'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y",
+		'ύ': "y", 'ϋ': "y", 'ΰ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o",
+		'ώ': "o",
+	}
+)
+
+// TransliterateASCII converts the text to ASCII for carrier labels, spelling
+// Cyrillic and Greek letters in Latin and removing diacritics. Letters that
+// cannot be transliterated (i.e. CJK) are dropped, see IsTransliterable
+func TransliterateASCII(s string) string {
+	ascii, _ := transliterate(s)
+	return ascii
+}
+
+// IsTransliterable reports whether every letter and digit of the text can be
+// written in ASCII
+func IsTransliterable(s string) bool {
+	_, ok := transliterate(s)
+	return ok
+}
+
+// transliterate returns the ASCII text, and false when letters or digits had
+// to be dropped from it
+func transliterate(s string) (string, bool) {
+	var transliterated strings.Builder
+	for _, r := range s {
+		lower := unicode.ToLower(r)
+		latin, ok := transliterations[lower]
+		if !ok {
+			transliterated.WriteRune(r)
+			continue
+		}
+
+		if lower != r && latin != "" {
+			latin = strings.ToUpper(latin[:1]) + latin[1:]
+		}
+		transliterated.WriteString(latin)
+	}
+
+	// The transformer keeps state so one is created for each call
+	removeDiacritics := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, err := transform.String(removeDiacritics, transliterated.String())
+	if err != nil {
+		folded = transliterated.String()
+	}
+
+	complete := true
+	ascii := strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII || !unicode.IsPrint(r) {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				complete = false
+			}
+			return -1
+		}
+		return r
+	}, folded)
+
+	return strings.Join(strings.Fields(ascii), " "), complete
+}
+
+// AddressLineLimit returns the shortest address line limit of the carriers
+// allowed for the destination, or 0 when none of them have a known limit
+func AddressLineLimit(c *config.Config, country string, state string) int {
+	limit := 0
+	for _, carrier := range c.CarriersInZone(c.ZoneFor(country, state)) {
+		carrierLimit, ok := CarrierAddressLineLimits[strings.TrimSpace(carrier)]
+		if ok && (limit == 0 || carrierLimit < limit) {
+			limit = carrierLimit
+		}
+	}
+
+	return limit
+}
+
+// SanitizeAddress transliterates the address to ASCII so carriers accept it
+// on labels. It returns a copy of the original address when anything changed,
+// or nil when nothing did. The address is left as is and shipping errors are
+// returned when any field has letters that cannot be transliterated, or a
+// transliterated line is longer than the line limit, as quoting it would cut
+// off part of the address
+func SanitizeAddress(address *easypost.Address, lineLimit int, locale *Locale) (*easypost.Address, *snipcart.ShippingErrors) {
+	sanitized := *address
+	lines := []*string{&sanitized.Name, &sanitized.Company, &sanitized.Street1, &sanitized.Street2, &sanitized.City}
+
+	changed := false
+	for _, field := range append(lines, &sanitized.State) {
+		ascii, ok := transliterate(*field)
+		if !ok {
+			return nil, &snipcart.ShippingErrors{
+				Errors: []snipcart.ShippingError{
+					{
+						Key:     "invalid_address_characters",
+						Message: locale.Text(MsgInvalidAddressCharacters),
+					},
+				},
+			}
+		}
+		if ascii != *field {
+			*field = ascii
+			changed = true
+		}
+	}
+
+	for _, line := range lines {
+		if lineLimit > 0 && len(*line) > lineLimit {
+			return nil, &snipcart.ShippingErrors{
+				Errors: []snipcart.ShippingError{
+					{
+						Key:     "invalid_address_line_length",
+						Message: locale.Text(MsgInvalidAddressLineLength, lineLimit),
+					},
+				},
+			}
+		}
+	}
+
+	if !changed {
+		return nil, nil
+	}
+
+	original := *address
+	*address = sanitized
+
+	return &original, nil
+}
+
+// OriginalAddressReference formats the address as the customer entered it for
+// the shipment's reference, so the original is kept with the shipment for the
+// commercial invoice while the label has the sanitized address
+func OriginalAddressReference(address *easypost.Address) string {
+	var parts []string
+	for _, part := range []string{address.Name, address.Company, FormatAddress(address), address.Country} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/EasyPost/easypost-go/v4"
+)
+
+func TestTransliterateASCII(t *testing.T) {
+	tests := []struct {
+		name           string
+		in             string
+		want           string
+		transliterable bool
+	}{
+		{"ascii", "123 Main St.", "123 Main St.", true},
+		{"diacritics", "Rue de l'Église, Besançon", "Rue de l'Eglise, Besancon", true},
+		{"latin letters", "Straße Łódź Ærø", "Strasse Lodz Aero", true},
+		{"cyrillic", "Ул. Льва Толстого 16", "Ul. Lva Tolstogo 16", true},
+		{"cyrillic digraphs", "Ёлка Щука", "Yolka Shchuka", true},
+		{"greek", "Αθήνα Ψαρρά", "Athina Psarra", true},
+		{"whitespace", "  Main  St  ", "Main St", true},
+		{"symbols", "Main St №5", "Main St 5", true},
+		{"cjk", "東京都 1-1", "1-1", false},
+		{"hangul", "서울 1", "1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TransliterateASCII(tt.in); got != tt.want {
+				t.Errorf("TransliterateASCII(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+
+			if got := IsTransliterable(tt.in); got != tt.transliterable {
+				t.Errorf("IsTransliterable(%q) = %t, want %t", tt.in, got, tt.transliterable)
+			}
+		})
+	}
+}
+
+func TestSanitizeAddress(t *testing.T) {
+	locale := NewLocale("en")
+
+	tests := []struct {
+		name        string
+		address     easypost.Address
+		lineLimit   int
+		want        easypost.Address
+		wantChanged bool
+		wantError   string
+	}{
+		{
+			name:        "transliterated",
+			address:     easypost.Address{Street1: "Ул. Льва Толстого 16", City: "Москва", Country: "RU"},
+			lineLimit:   35,
+			want:        easypost.Address{Street1: "Ul. Lva Tolstogo 16", City: "Moskva", Country: "RU"},
+			wantChanged: true,
+		},
+		{
+			name:      "unchanged",
+			address:   easypost.Address{Street1: "123 Main St", City: "Springfield", Country: "US"},
+			lineLimit: 35,
+			want:      easypost.Address{Street1: "123 Main St", City: "Springfield", Country: "US"},
+		},
+		{
+			name:      "untransliterable",
+			address:   easypost.Address{Street1: "千代田1-1", City: "東京都", Country: "JP"},
+			lineLimit: 35,
+			want:      easypost.Address{Street1: "千代田1-1", City: "東京都", Country: "JP"},
+			wantError: "invalid_address_characters",
+		},
+		{
+			name:      "too long after transliteration",
+			address:   easypost.Address{Street1: "Ул. Льва Толстого 16", City: "Москва", Country: "RU"},
+			lineLimit: 18,
+			want:      easypost.Address{Street1: "Ул. Льва Толстого 16", City: "Москва", Country: "RU"},
+			wantError: "invalid_address_line_length",
+		},
+		{
+			name:      "too long",
+			address:   easypost.Address{Street1: "1234 Long Street Name Apartment 56", City: "Springfield", Country: "US"},
+			lineLimit: 30,
+			want:      easypost.Address{Street1: "1234 Long Street Name Apartment 56", City: "Springfield", Country: "US"},
+			wantError: "invalid_address_line_length",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address := tt.address
+
+			original, shippingErrors := SanitizeAddress(&address, tt.lineLimit, locale)
+
+			if address != tt.want {
+				t.Errorf("address = %+v, want %+v", address, tt.want)
+			}
+
+			if tt.wantError != "" {
+				if shippingErrors == nil || shippingErrors.Errors[0].Key != tt.wantError {
+					t.Errorf("errors = %+v, want %s", shippingErrors, tt.wantError)
+				}
+				return
+			}
+
+			if shippingErrors != nil {
+				t.Fatalf("unexpected errors %+v", shippingErrors)
+			}
+
+			if tt.wantChanged && (original == nil || *original != tt.address) {
+				t.Errorf("original = %+v, want %+v", original, tt.address)
+			}
+
+			if !tt.wantChanged && original != nil {
+				t.Errorf("original = %+v, want nil", original)
+			}
+		})
+	}
+}
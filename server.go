@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// ServeCommand parses the arguments of the serve command (i.e. `serve --addr
+// :8080`) and runs the webhook as a standalone HTTP server
+func ServeCommand(args []string) error {
+	serveFlags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := serveFlags.String("addr", ":8080", "address for the HTTP server to listen on")
+	shutdownTimeout := serveFlags.Duration("shutdown-timeout", 10*time.Second, "time to wait for in-flight requests on shutdown")
+
+	if err := serveFlags.Parse(args); err != nil {
+		return err
+	}
+
+	return Serve(*addr, *shutdownTimeout)
+}
+
+// Serve runs the same gin router used by the Lambda handler as a plain HTTP
+// server, shutting down gracefully when SIGINT or SIGTERM is received
+func Serve(addr string, shutdownTimeout time.Duration) error {
+	if ginEngine == nil {
+		return errors.New("webhook router was not initialized, check configuration")
+	}
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           ginEngine,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		logJson("server.start", fmt.Sprintf("listening on %s (version %s)", addr, BuildVersion))
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+		close(serverErr)
+	}()
+
+	select {
+	case err := <-serverErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	logJson("server.shutdown", fmt.Sprintf("shutting down, waiting up to %s for requests", shutdownTimeout))
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("error with server shutdown: %s", err.Error())
+	}
+
+	logJson("server.shutdown", "completed")
+	return nil
+}
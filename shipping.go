@@ -2,10 +2,12 @@ package main
 
 import (
 	"fmt"
+	"math"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
@@ -19,8 +21,11 @@ type ShippingRate struct {
 	Id          string  `json:"userDefinedId"`
 	Cost        float64 `json:"cost"`
 	Description string  `json:"description"`
-	// We cannot guarantee days to delivery, kept here for debug future
-	// DeliveryDays int     `json:"guaranteedDaysToDelivery"`
+	// Only set when the carrier guarantees the delivery date
+	GuaranteedDaysToDelivery int `json:"guaranteedDaysToDelivery,omitempty"`
+
+	// deliveryDays is the estimate used for curating rates, not sent to Snipcart
+	deliveryDays int
 }
 
 type ShippingRatesResponse struct {
@@ -49,7 +54,7 @@ func CarrierServiceNameCleanup(carrier string, service string) string {
 	return cleanedService
 }
 
-func FormatRateServiceName(service string) string {
+func FormatRateServiceName(service string, tag language.Tag) string {
 	// Regex to add and replace camelcase with spaces between camelcase words
 	// for readability
 	serviceRe := regexp.MustCompile(`([a-z])([A-Z])`)
@@ -57,14 +62,15 @@ func FormatRateServiceName(service string) string {
 	serviceUncameled := serviceRe.ReplaceAllString(serviceSpaced, "$1 $2")
 	serviceLowered := strings.ToLower(serviceUncameled)
 
-	serviceTitleCase := cases.Title(language.English)
+	serviceTitleCase := cases.Title(tag)
 	return serviceTitleCase.String(serviceLowered)
 }
 
 func GenerateCustomsItems(order *snipcart.Order) []*easypost.CustomsItem {
 	var customsItems []*easypost.CustomsItem
 
-	for _, v := range order.Items {
+	for i := range order.Items {
+		v := &order.Items[i]
 		if !v.Shippable {
 			logJson("shippingratches.fetch", fmt.Sprintf("order %s item %s not shippable, skipping", order.Token, v.Name))
 			continue
@@ -72,38 +78,87 @@ func GenerateCustomsItems(order *snipcart.Order) []*easypost.CustomsItem {
 
 		if strings.ToLower(order.Country) != "us" {
 			// International
-			customsItem := easypost.CustomsItem{
-				Description:   v.Name,
-				Quantity:      float64(v.Quantity),
-				Weight:        v.Weight,
-				Value:         v.TotalPrice,
-				OriginCountry: webhookConfig.SenderAddress.Country,
-				Code:          order.Invoice,
-				Currency:      order.Currency,
-			}
-
-			// Handle tariff numbers
-			for _, f := range v.CustomFields {
-				if f.Name == "hs_code" {
-					customsItem.HSTariffNumber = f.Value
-				}
-			}
-
-			customsItems = append(customsItems, &customsItem)
+			customsItems = append(customsItems, newCustomsItem(order, v, v.Quantity))
+		}
+	}
+
+	return customsItems
+}
+
+// ParcelCustomsItems returns the customs items for the units of the order's
+// items packed in one parcel, so that each parcel of a multi-parcel order only
+// declares its own contents and value
+func ParcelCustomsItems(order *snipcart.Order, packed []PackItem) []*easypost.CustomsItem {
+	var items []*snipcart.Item
+	quantities := make(map[*snipcart.Item]int)
+
+	for _, unit := range packed {
+		if unit.Item == nil {
+			continue
 		}
+
+		if quantities[unit.Item] == 0 {
+			items = append(items, unit.Item)
+		}
+		quantities[unit.Item]++
+	}
+
+	var customsItems []*easypost.CustomsItem
+	for _, item := range items {
+		customsItems = append(customsItems, newCustomsItem(order, item, quantities[item]))
 	}
 
 	return customsItems
 }
 
-func SetInternationalInfo(shipment *easypost.Shipment, order *snipcart.Order) {
+// newCustomsItem creates the customs item for a quantity of the order item,
+// valued at its share of the item's total price
+func newCustomsItem(order *snipcart.Order, item *snipcart.Item, quantity int) *easypost.CustomsItem {
+	value := item.TotalPrice
+	if item.Quantity > 0 && quantity != item.Quantity {
+		value = item.TotalPrice * float64(quantity) / float64(item.Quantity)
+	}
+
+	return &easypost.CustomsItem{
+		Description:   item.Name,
+		Quantity:      float64(quantity),
+		Weight:        item.Weight,
+		Value:         value,
+		OriginCountry: webhookConfig.SenderAddress.Country,
+		Code:          order.Invoice,
+		Currency:      order.Currency,
+		// Handle tariff numbers
+		HSTariffNumber: ItemCustomField(item, "hs_code"),
+	}
+}
+
+// SetZoneCustoms overrides the customs info with the zone's customs settings
+func SetZoneCustoms(customsInfo *easypost.CustomsInfo, zoneCustoms *config.ZoneCustoms) {
+	if zoneCustoms.ContentsType != "" {
+		customsInfo.ContentsType = zoneCustoms.ContentsType
+	}
+
+	if zoneCustoms.RestrictionType != "" {
+		customsInfo.RestrictionType = zoneCustoms.RestrictionType
+	}
+
+	if zoneCustoms.NonDeliveryOption != "" {
+		customsInfo.NonDeliveryOption = zoneCustoms.NonDeliveryOption
+	}
+
+	if zoneCustoms.EELPFC != "" {
+		customsInfo.EELPFC = zoneCustoms.EELPFC
+	}
+}
+
+func SetInternationalInfo(shipment *easypost.Shipment, order *WebhookOrder) {
 	DebugPrintf("setting international info for order %s", order.Invoice)
 	shipment.CustomsInfo = &easypost.CustomsInfo{
 		CustomsCertify:    true,
 		CustomsSigner:     webhookConfig.CustomsVerifier,
 		RestrictionType:   RSTRCTTYP_NONE,
 		EELPFC:            EEL_NOEEI3037a,
-		CustomsItems:      GenerateCustomsItems(order),
+		CustomsItems:      GenerateCustomsItems(&order.Order),
 		NonDeliveryOption: NONDELIV_RETURN, // TODO: do we ever want to abandon?
 		ContentsType:      CONTYP_MERCH,
 	}
@@ -113,18 +168,96 @@ func SetInternationalInfo(shipment *easypost.Shipment, order *snipcart.Order) {
 		shipment.CustomsInfo.EELPFC = EEL_NOEEI3036
 	}
 
-	/* Handle EU IOSS */
-	if IsEUCountry(order.Country) {
-		shipment.TaxIdentifiers = append(shipment.TaxIdentifiers,
-			&easypost.TaxIdentifier{
-				Entity:         TAXENT_SENDER,
-				IssuingCountry: "ES",
-				TaxId:          webhookConfig.IOSS,
-				TaxIdType:      "IOSS",
-			})
+	/* Handle customs settings of the destination zone */
+	if zone := webhookConfig.ZoneFor(order.ShippingAddress.Country, order.ShippingAddress.Province); zone != nil && zone.Customs != nil {
+		SetZoneCustoms(shipment.CustomsInfo, zone.Customs)
+	}
+
+	/* Handle EU IOSS, UK VAT, and Norway VOEC */
+	if scheme := ImportVATSchemeFor(order.Country); scheme != nil {
+		if ImportVATApplies(scheme, order.Items, order.Subtotal(), order.Currency, "shippingrates.fetch") {
+			shipment.TaxIdentifiers = append(shipment.TaxIdentifiers,
+				&easypost.TaxIdentifier{
+					Entity:         TAXENT_SENDER,
+					IssuingCountry: scheme.IssuingCountry,
+					TaxId:          scheme.TaxId(webhookConfig),
+					TaxIdType:      scheme.TaxIdType,
+				})
+		} else {
+			SetDutiesUnpaid(shipment)
+		}
+	}
+
+	/* Handle Switzerland and others where import VAT is paid on delivery */
+	if IsDutiesUnpaidCountry(order.Country) {
+		SetDutiesUnpaid(shipment)
+	}
+}
+
+// Coupons returns the discount codes used on the order
+func (o *WebhookOrder) Coupons() []string {
+	var coupons []string
+	for _, discount := range o.Discounts {
+		if discount.Code != "" {
+			coupons = append(coupons, discount.Code)
+		}
 	}
 
-	// TODO: Handle UK VAT
+	return coupons
+}
+
+// Subtotal returns the order's items total, summing the items when Snipcart
+// does not provide it
+func (o *WebhookOrder) Subtotal() float64 {
+	if o.ItemsTotal > 0 {
+		return o.ItemsTotal
+	}
+
+	var subtotal float64
+	for _, item := range o.Items {
+		subtotal += item.TotalPrice
+	}
+
+	return subtotal
+}
+
+// NewRateContext creates the context that shipping rules are matched against
+// for the order, without a carrier or service set
+func NewRateContext(order *WebhookOrder) config.RateContext {
+	return config.RateContext{
+		Zone:     webhookConfig.ZoneNameFor(order.ShippingAddress.Country, order.ShippingAddress.Province),
+		Country:  order.ShippingAddress.Country,
+		State:    order.ShippingAddress.Province,
+		Subtotal: order.Subtotal(),
+		Coupons:  order.Coupons(),
+	}
+}
+
+// ApplyShippingRules applies every configured shipping rule that matches the
+// rate to its cost, in the order they are configured. Free shipping ends rule
+// processing
+func ApplyShippingRules(rules []config.ShippingRule, ctx *config.RateContext, cost float64) float64 {
+	for i := range rules {
+		rule := &rules[i]
+		if !rule.Matches(ctx) {
+			continue
+		}
+
+		switch rule.Type {
+		case config.ShippingRuleFreeShipping:
+			return 0.00
+		case config.ShippingRuleFlatRate:
+			cost = rule.Cost
+		case config.ShippingRuleAdjust:
+			cost = cost*(1+rule.Percent/100) + rule.Amount
+		}
+	}
+
+	if cost < 0 {
+		return 0.00
+	}
+
+	return math.Round(cost*100) / 100
 }
 
 func DiscountedCost(shippingCost float64, discount int) float64 {
@@ -137,39 +270,65 @@ func DiscountedCost(shippingCost float64, discount int) float64 {
 	return discountedCost
 }
 
-// ShippingRateDescription takes the carrier, service, and estimated days to
-// form a valid description by adding spaces to the upper camel case of the
-// service level from EasyPost and appends an estimated days message if estimate
-// provided
-func ShippingRateDescription(carrier string, service string, deliveryDays int, guaranteedDelivery bool) string {
+// ShippingRateDescription takes the carrier, service, and delivery window to
+// form a valid description in the customer's language by adding spaces to the
+// upper camel case of the service level from EasyPost and appends an arrival
+// message if a delivery window is provided
+func ShippingRateDescription(locale *Locale, carrier string, service string, window *DeliveryWindow) string {
 	carrierRenamed := CarrierRename(carrier)
 	serviceCleaned := CarrierServiceNameCleanup(carrierRenamed, service)
-	serviceFormatted := FormatRateServiceName(serviceCleaned)
+	serviceFormatted := FormatRateServiceName(serviceCleaned, locale.Tag)
 
 	description := fmt.Sprintf("%s %s", carrierRenamed, serviceFormatted)
 
-	if deliveryDays > 0 {
+	if window != nil {
+		arrival := locale.FormatDate(window.Earliest)
+		if !window.Latest.Equal(window.Earliest) {
+			arrival = fmt.Sprintf("%s - %s", arrival, locale.FormatDate(window.Latest))
+		}
+
 		// Add estimation to description if provided
-		arrivalGuarantee := "Estimated"
-		if guaranteedDelivery {
-			arrivalGuarantee = "Guaranteed"
+		arrivalMessage := MsgEstimatedArrival
+		if window.Guaranteed {
+			arrivalMessage = MsgGuaranteedArrival
 		}
 
-		description = fmt.Sprintf("%s - %s arrival %d days", description, arrivalGuarantee, deliveryDays)
+		description = fmt.Sprintf("%s - %s", description, locale.Text(arrivalMessage, arrival))
 	}
 
 	return description
 }
 
-// GenerateSnipcartRates takes a list of EasyPost shipping rates and returns an
-// object with the list converted to what Snipcart expects as a return
+// RateDeliveryDays returns the carrier's delivery days for the rate, or
+// EasyPost's estimate when the carrier does not provide any
+func RateDeliveryDays(rate *easypost.Rate) int {
+	if rate.DeliveryDays > 0 {
+		return rate.DeliveryDays
+	}
+
+	return rate.EstDeliveryDays
+}
+
+// GenerateSnipcartRates takes the EasyPost shipping rates of a quote and
+// returns an object with the list converted to what Snipcart expects as a
+// return, described in the customer's language. Rates are filtered by the
+// carrier rules, have the configured shipping rules applied to their cost,
+// and are curated down to the rates worth showing. Delivery is estimated from
+// shipDate, the date the quote's shipments were created to ship on
 // https://docs.snipcart.com/v3/webhooks/shipping
-func GenerateSnipcartRates(config *config.Config, rates []*easypost.Rate) (*ShippingRatesResponse, error) {
+func GenerateSnipcartRates(config *config.Config, quote *Quote, shipDate time.Time, rateContext config.RateContext, locale *Locale) (*ShippingRatesResponse, error) {
 	var ratesResponse ShippingRatesResponse
 
-	for _, rate := range rates {
+	zone := config.ZoneFor(rateContext.Country, rateContext.State)
+
+	ratedAt := time.Now().In(config.Location)
+
+	for _, rate := range quote.Rates {
+		rateContext.Carrier = rate.Carrier
+		rateContext.Service = rate.Service
+
 		// Skip disallowed rates
-		if !config.CarrierAllowed(rate.Carrier) {
+		if !config.RateAllowed(&rateContext, zone) {
 			continue
 		}
 
@@ -179,17 +338,28 @@ func GenerateSnipcartRates(config *config.Config, rates []*easypost.Rate) (*Ship
 			return nil, err
 		}
 
+		deliveryWindow := EstimateDelivery(rate, shipDate)
+
 		ratesResponse.Rates = append(ratesResponse.Rates, ShippingRate{
-			Id:          rate.ID,
-			Cost:        DiscountedCost(cost, config.ShippingDiscount),
-			Description: ShippingRateDescription(rate.Carrier, rate.Service, rate.EstDeliveryDays, rate.DeliveryDateGuaranteed),
+			Id:                       quote.RateId(rate),
+			Cost:                     ApplyShippingRules(config.ShippingRules, &rateContext, DiscountedCost(cost, config.ShippingDiscount)),
+			Description:              ShippingRateDescription(locale, rate.Carrier, rate.Service, deliveryWindow),
+			GuaranteedDaysToDelivery: deliveryWindow.GuaranteedDays(ratedAt),
+
+			deliveryDays: RateDeliveryDays(rate),
 		})
 	}
 
 	// Sort by lowest rate first
-	sort.Slice(ratesResponse.Rates, func(i, j int) bool {
+	sort.SliceStable(ratesResponse.Rates, func(i, j int) bool {
 		return ratesResponse.Rates[i].Cost < ratesResponse.Rates[j].Cost
 	})
 
+	curatedRates, err := CurateRates(config, ratesResponse.Rates, locale)
+	if err != nil {
+		return nil, err
+	}
+	ratesResponse.Rates = curatedRates
+
 	return &ratesResponse, nil
 }
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/debyltech/go-snipcart-webhook/config"
+)
+
+func TestApplyShippingRules(t *testing.T) {
+	markup := config.ShippingRule{Type: config.ShippingRuleAdjust, Percent: 10}
+	flat := config.ShippingRule{Type: config.ShippingRuleFlatRate, Cost: 5}
+	free := config.ShippingRule{Type: config.ShippingRuleFreeShipping, MinSubtotal: 100}
+	uspsDiscount := config.ShippingRule{Type: config.ShippingRuleAdjust, Carriers: []string{"usps"}, Amount: -2}
+	couponFree := config.ShippingRule{Type: config.ShippingRuleFreeShipping, Coupons: []string{"FREESHIP"}}
+	euFlat := config.ShippingRule{Type: config.ShippingRuleFlatRate, Zones: []string{config.ZoneEU}, Cost: 20}
+
+	ctx := func(subtotal float64, coupons ...string) *config.RateContext {
+		return &config.RateContext{
+			Zone:     config.ZoneDomestic,
+			Country:  "US",
+			Carrier:  "USPS",
+			Service:  "Priority",
+			Subtotal: subtotal,
+			Coupons:  coupons,
+		}
+	}
+
+	tests := []struct {
+		name  string
+		rules []config.ShippingRule
+		ctx   *config.RateContext
+		cost  float64
+		want  float64
+	}{
+		{"no rules", nil, ctx(50), 10.456, 10.46},
+		{"markup", []config.ShippingRule{markup}, ctx(50), 10, 11},
+		{"flat rate after markup", []config.ShippingRule{markup, flat}, ctx(50), 10, 5},
+		{"markup after flat rate", []config.ShippingRule{flat, markup}, ctx(50), 10, 5.5},
+		{"free shipping at subtotal", []config.ShippingRule{free}, ctx(100), 10, 0},
+		{"no free shipping below subtotal", []config.ShippingRule{free}, ctx(99.99), 10, 10},
+		{"free shipping ends processing", []config.ShippingRule{free, flat}, ctx(100), 10, 0},
+		{"carrier condition", []config.ShippingRule{uspsDiscount}, ctx(50), 10, 8},
+		{"adjust below zero", []config.ShippingRule{uspsDiscount}, ctx(50), 1, 0},
+		{"coupon condition", []config.ShippingRule{couponFree}, ctx(50, "freeship"), 10, 0},
+		{"coupon not used", []config.ShippingRule{couponFree}, ctx(50, "OTHER"), 10, 10},
+		{"other zone", []config.ShippingRule{euFlat}, ctx(50), 10, 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ApplyShippingRules(tt.rules, tt.ctx, tt.cost); got != tt.want {
+				t.Errorf("ApplyShippingRules = %f, want %f", got, tt.want)
+			}
+		})
+	}
+}
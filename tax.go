@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/debyltech/go-snipcart/snipcart"
+)
+
+type TaxShippingInformation struct {
+	Fees   float64 `json:"fees"`
+	Method string  `json:"method"`
+}
+
+type TaxCalculateContent struct {
+	Token                string                 `json:"token"`
+	CreationDate         time.Time              `json:"creationDate"`
+	Currency             string                 `json:"currency"`
+	ItemsTotal           float64                `json:"itemsTotal"`
+	Items                []snipcart.Item        `json:"items"`
+	ShippingAddress      snipcart.Address       `json:"shippingAddress"`
+	BillingAddress       snipcart.Address       `json:"billingAddress"`
+	ShipToBillingAddress bool                   `json:"shipToBillingAddress"`
+	ShippingInformation  TaxShippingInformation `json:"shippingInformation"`
+	Discounts            []WebhookDiscount      `json:"discounts"`
+}
+
+type TaxCalculateWebhookEvent struct {
+	EventName string              `json:"eventName"`
+	CreatedOn time.Time           `json:"createdOn"`
+	Content   TaxCalculateContent `json:"content"`
+}
+
+// FormatTaxPercent formats a tax rate as a percentage for invoices, keeping
+// fractional percentages (i.e. 0.255 is "25.5%")
+func FormatTaxPercent(rate float64) string {
+	return strconv.FormatFloat(float64(int64(rate*10000+0.5))/100, 'f', -1, 64) + "%"
+}
+
+// ShippingTaxable returns the shipping fees of the order after any shipping
+// discounts, which Snipcart reports separately from the fees
+func ShippingTaxable(content *TaxCalculateContent) float64 {
+	fees := content.ShippingInformation.Fees
+
+	for _, discount := range content.Discounts {
+		if discount.Type == "Shipping" {
+			fees -= discount.AmountSaved
+		}
+	}
+
+	if fees < 0 {
+		return 0.00
+	}
+
+	return fees
+}
+
+// VATRate returns the VAT rate of the country for the product tax category in
+// effect at the given time, using the configured VAT rates and falling back to
+// the standard rate in EUCountryVAT or ImportCountryVAT for countries without
+// any configured rate
+func VATRate(country string, category string, at time.Time) float64 {
+	if rate, ok := webhookConfig.VATRateAt(country, at); ok {
+		return rate.CategoryRate(category)
+	}
+
+	if rate, ok := EUCountryVAT[strings.ToLower(country)]; ok {
+		return rate
+	}
+
+	return ImportCountryVAT[strings.ToLower(country)]
+}
+
+// VATTaxes returns the VAT lines for the address at the rates in effect when
+// the order was created. Each item is taxed at the rate of its tax category,
+// set with the tax_category custom field, with one line returned per rate.
+// Shipping is taxed at the standard rate unless disabled in the config
+func VATTaxes(content *TaxCalculateContent, address *snipcart.Address, createdOn time.Time) []snipcart.Tax {
+	if createdOn.IsZero() {
+		createdOn = time.Now()
+	}
+
+	standardRate := VATRate(address.Country, "", createdOn)
+	DebugPrintf("using standard VAT rate %f for %s at %s", standardRate, address.Country, createdOn.Format(time.RFC3339))
+
+	taxableByRate := make(map[float64]float64)
+
+	if len(content.Items) == 0 {
+		taxableByRate[standardRate] += content.ItemsTotal
+	} else {
+		// ItemsTotal has discounts applied, so spread them across the items
+		discountFactor := DiscountFactor(content.Items, content.ItemsTotal)
+
+		for i := range content.Items {
+			item := &content.Items[i]
+			rate := VATRate(address.Country, ItemCustomField(item, "tax_category"), createdOn)
+			taxableByRate[rate] += item.TotalPrice * discountFactor
+		}
+	}
+
+	if webhookConfig.VATOnShipping {
+		taxableByRate[standardRate] += ShippingTaxable(content)
+	}
+
+	return vatTaxLines(address.Country, standardRate, taxableByRate)
+}
+
+// vatTaxLines creates a tax line for each rate, highest rate first
+func vatTaxLines(country string, standardRate float64, taxableByRate map[float64]float64) []snipcart.Tax {
+	rates := make([]float64, 0, len(taxableByRate))
+	for rate := range taxableByRate {
+		rates = append(rates, rate)
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(rates)))
+
+	var taxes []snipcart.Tax
+	for _, rate := range rates {
+		name := "VAT"
+		if rate != standardRate {
+			name = "Reduced VAT"
+		}
+
+		taxes = append(taxes, snipcart.Tax{
+			Name:             name,
+			Amount:           taxableByRate[rate] * rate,
+			NumberForInvoice: fmt.Sprintf("%s - %s", strings.ToUpper(country), FormatTaxPercent(rate)),
+			Rate:             rate,
+		})
+	}
+
+	return taxes
+}
+
+// ImportVATTaxes returns the VAT lines for destinations with an import VAT
+// scheme, or no lines when the scheme does not apply to the order and the
+// customer pays import VAT on delivery instead
+func ImportVATTaxes(content *TaxCalculateContent, address *snipcart.Address, createdOn time.Time, scheme *ImportVATScheme) []snipcart.Tax {
+	if !ImportVATApplies(scheme, content.Items, content.ItemsTotal, content.Currency, "taxes.calculate") {
+		DebugPrintf("%s does not apply to order %s, not collecting VAT", scheme.Name, content.Token)
+		return nil
+	}
+
+	return VATTaxes(content, address, createdOn)
+}
+
+// SalesTaxes returns the US sales tax lines for the address, based on the
+// configured nexus states. No taxes are returned for states without nexus
+func SalesTaxes(content *TaxCalculateContent, address *snipcart.Address) []snipcart.Tax {
+	rule := webhookConfig.SalesTaxFor(address.Province)
+	if rule == nil {
+		DebugPrintf("no sales tax nexus for state %s", address.Province)
+		return nil
+	}
+
+	rate := rule.RateFor(address.PostalCode)
+
+	taxable := content.ItemsTotal
+	if rule.TaxShipping {
+		taxable += ShippingTaxable(content)
+	}
+
+	name := rule.Name
+	if name == "" {
+		name = fmt.Sprintf("%s Sales Tax", strings.ToUpper(rule.State))
+	}
+
+	DebugPrintf("applying %s sales tax rate %f to %f", rule.State, rate, taxable)
+
+	return []snipcart.Tax{
+		{
+			Name:             name,
+			Amount:           taxable * rate,
+			NumberForInvoice: fmt.Sprintf("%s - %s", strings.ToUpper(rule.State), FormatTaxPercent(rate)),
+			Rate:             rate,
+		},
+	}
+}
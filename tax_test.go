@@ -0,0 +1,168 @@
+package main
+
+import (
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/debyltech/go-snipcart-webhook/config"
+	"github.com/debyltech/go-snipcart/snipcart"
+)
+
+func vatDate(s string) config.Date {
+	t, _ := time.Parse(config.DateLayout, s)
+	return config.Date{Time: t}
+}
+
+func TestVATTaxes(t *testing.T) {
+	book := snipcart.Item{
+		Name:         "Book",
+		TotalPrice:   100,
+		CustomFields: []snipcart.CustomField{{Name: "tax_category", Value: "books"}},
+	}
+	poster := snipcart.Item{
+		Name:       "Poster",
+		TotalPrice: 50,
+	}
+
+	createdOn := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		country       string
+		createdOn     time.Time
+		vatOnShipping bool
+		content       TaxCalculateContent
+		want          map[string]float64
+	}{
+		{
+			name:      "per rate lines",
+			country:   "DE",
+			createdOn: createdOn,
+			content: TaxCalculateContent{
+				ItemsTotal: 150,
+				Items:      []snipcart.Item{book, poster},
+			},
+			want: map[string]float64{"VAT": 50 * 0.19, "Reduced VAT": 100 * 0.07},
+		},
+		{
+			name:      "discount spread across items",
+			country:   "DE",
+			createdOn: createdOn,
+			content: TaxCalculateContent{
+				ItemsTotal: 120,
+				Items:      []snipcart.Item{book, poster},
+			},
+			want: map[string]float64{"VAT": 40 * 0.19, "Reduced VAT": 80 * 0.07},
+		},
+		{
+			name:          "discounted shipping at standard rate",
+			country:       "DE",
+			createdOn:     createdOn,
+			vatOnShipping: true,
+			content: TaxCalculateContent{
+				ItemsTotal:          50,
+				Items:               []snipcart.Item{poster},
+				ShippingInformation: TaxShippingInformation{Fees: 10},
+				Discounts:           []WebhookDiscount{{Type: "Shipping", AmountSaved: 4}},
+			},
+			want: map[string]float64{"VAT": 56 * 0.19},
+		},
+		{
+			name:      "rate before change",
+			country:   "FI",
+			createdOn: time.Date(2024, 8, 31, 12, 0, 0, 0, time.UTC),
+			content: TaxCalculateContent{
+				ItemsTotal: 50,
+				Items:      []snipcart.Item{poster},
+			},
+			want: map[string]float64{"VAT": 50 * 0.24},
+		},
+		{
+			name:      "rate after change",
+			country:   "FI",
+			createdOn: createdOn,
+			content: TaxCalculateContent{
+				ItemsTotal: 50,
+				Items:      []snipcart.Item{poster},
+			},
+			want: map[string]float64{"VAT": 50 * 0.255},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			webhookConfig = &config.Config{
+				Production:    true,
+				VATOnShipping: tt.vatOnShipping,
+				VATRates: map[string][]config.VATRate{
+					"de": {{EffectiveFrom: vatDate("2021-01-01"), Rate: 0.19, Categories: map[string]float64{"books": 0.07}}},
+					"fi": {
+						{EffectiveFrom: vatDate("2013-01-01"), Rate: 0.24},
+						{EffectiveFrom: vatDate("2024-09-01"), Rate: 0.255},
+					},
+				},
+			}
+
+			taxes := VATTaxes(&tt.content, &snipcart.Address{Country: tt.country}, tt.createdOn)
+
+			if len(taxes) != len(tt.want) {
+				t.Fatalf("got %d tax lines, want %d: %+v", len(taxes), len(tt.want), taxes)
+			}
+
+			for i, tax := range taxes {
+				want, ok := tt.want[tax.Name]
+				if !ok {
+					t.Fatalf("unexpected tax line %q", tax.Name)
+				}
+
+				if math.Abs(tax.Amount-want) > 0.0001 {
+					t.Errorf("%s amount = %f, want %f", tax.Name, tax.Amount, want)
+				}
+
+				if i > 0 && tax.Rate > taxes[i-1].Rate {
+					t.Errorf("tax lines not sorted highest rate first: %+v", taxes)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleTaxCalculationCreationDate(t *testing.T) {
+	webhookConfig = &config.Config{
+		Production: true,
+		IOSS:       "IM1234567890",
+		VATRates: map[string][]config.VATRate{
+			"fi": {
+				{EffectiveFrom: vatDate("2013-01-01"), Rate: 0.24},
+				{EffectiveFrom: vatDate("2024-09-01"), Rate: 0.255},
+			},
+		},
+	}
+
+	// The webhook is delivered after the rate change for an order created
+	// before it, so the old rate applies
+	body := `{
+		"eventName": "taxes.calculate",
+		"createdOn": "2024-09-01T00:05:00Z",
+		"content": {
+			"token": "token",
+			"creationDate": "2024-08-31T23:55:00Z",
+			"currency": "eur",
+			"itemsTotal": 100,
+			"items": [{"name": "Poster", "totalPrice": 100, "shippable": true}],
+			"shippingAddress": {"country": "FI"}
+		}
+	}`
+
+	taxes, err := HandleTaxCalculation(io.NopCloser(strings.NewReader(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(taxes.Taxes) != 1 || taxes.Taxes[0].Rate != 0.24 {
+		t.Errorf("taxes = %+v, want one line at 0.24", taxes.Taxes)
+	}
+}